@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// photoAdjustments are applied to the cropped photo before the layers are drawn. Each is off when zero.
+type photoAdjustments struct {
+	Levels     float64 // auto-levels: stretch the tones so this percentage of pixels clip at each end
+	Contrast   float64 // -100 to 100
+	Saturation float64 // -100 to 100
+	Sharpen    float64 // sigma of the unsharp mask, e.g. 0.5
+	Vignette   float64 // 0 to 1: how much the corners are darkened
+}
+
+// The value for adjustments written without one, e.g. "levels".
+var defaultAdjustments = photoAdjustments{
+	Levels:     0.5,
+	Contrast:   10,
+	Saturation: 15,
+	Sharpen:    0.5,
+	Vignette:   0.3,
+}
+
+// parseAdjustments reads comma separated adjustments, e.g. "levels, contrast=20, vignette=0.2", over the top of base.
+// Setting an adjustment to 0 turns it off, and "none" turns them all off.
+func parseAdjustments(s string, base photoAdjustments) (photoAdjustments, error) {
+	adj := base
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if part == "none" {
+			adj = photoAdjustments{}
+			continue
+		}
+		name, value := part, ""
+		if i := strings.Index(part, "="); i > -1 {
+			name, value = strings.TrimSpace(part[:i]), strings.TrimSpace(part[i+1:])
+		}
+		var field, def *float64
+		switch name {
+		case "levels":
+			field, def = &adj.Levels, &defaultAdjustments.Levels
+		case "contrast":
+			field, def = &adj.Contrast, &defaultAdjustments.Contrast
+		case "saturation":
+			field, def = &adj.Saturation, &defaultAdjustments.Saturation
+		case "sharpen":
+			field, def = &adj.Sharpen, &defaultAdjustments.Sharpen
+		case "vignette":
+			field, def = &adj.Vignette, &defaultAdjustments.Vignette
+		default:
+			return photoAdjustments{}, fmt.Errorf("unknown adjustment %q", name)
+		}
+		if value == "" {
+			*field = *def
+			continue
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return photoAdjustments{}, fmt.Errorf("adjustment %q should be a number: %w", part, err)
+		}
+		*field = v
+	}
+	switch {
+	case adj.Levels < 0 || adj.Levels >= 50:
+		return photoAdjustments{}, fmt.Errorf("levels %g should be a percentage from 0 to 50", adj.Levels)
+	case adj.Contrast < -100 || adj.Contrast > 100:
+		return photoAdjustments{}, fmt.Errorf("contrast %g should be from -100 to 100", adj.Contrast)
+	case adj.Saturation < -100 || adj.Saturation > 100:
+		return photoAdjustments{}, fmt.Errorf("saturation %g should be from -100 to 100", adj.Saturation)
+	case adj.Sharpen < 0:
+		return photoAdjustments{}, fmt.Errorf("sharpen %g can't be negative", adj.Sharpen)
+	case adj.Vignette < 0 || adj.Vignette > 1:
+		return photoAdjustments{}, fmt.Errorf("vignette %g should be from 0 to 1", adj.Vignette)
+	}
+	return adj, nil
+}
+
+// String lists the adjustments that are on, in the same format parseAdjustments reads, or "none".
+func (adj photoAdjustments) String() string {
+	var parts []string
+	for _, a := range []struct {
+		name  string
+		value float64
+	}{
+		{"levels", adj.Levels},
+		{"contrast", adj.Contrast},
+		{"saturation", adj.Saturation},
+		{"sharpen", adj.Sharpen},
+		{"vignette", adj.Vignette},
+	} {
+		if a.value != 0 {
+			parts = append(parts, fmt.Sprintf("%s=%g", a.name, a.value))
+		}
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// adjustPhoto applies the adjustments in order: levels, contrast, saturation, sharpen, then vignette.
+func adjustPhoto(img *image.NRGBA, adj photoAdjustments) *image.NRGBA {
+	if adj.Levels != 0 {
+		img = autoLevels(img, adj.Levels)
+	}
+	if adj.Contrast != 0 {
+		img = imaging.AdjustContrast(img, adj.Contrast)
+	}
+	if adj.Saturation != 0 {
+		img = imaging.AdjustSaturation(img, adj.Saturation)
+	}
+	if adj.Sharpen != 0 {
+		img = imaging.Sharpen(img, adj.Sharpen)
+	}
+	if adj.Vignette != 0 {
+		img = vignette(img, adj.Vignette)
+	}
+	return img
+}
+
+// autoLevels stretches the luminance histogram so clip percent of the pixels are black and clip percent are white,
+// scaling the channels together so the colours don't shift.
+func autoLevels(img *image.NRGBA, clip float64) *image.NRGBA {
+	var histogram [256]int
+	var total int
+	for i := 0; i < len(img.Pix); i += 4 {
+		r, g, b := float64(img.Pix[i]), float64(img.Pix[i+1]), float64(img.Pix[i+2])
+		histogram[int(0.299*r+0.587*g+0.114*b+0.5)]++
+		total++
+	}
+	limit := int(float64(total) * clip / 100)
+	lo, hi := 0, 255
+	for n := histogram[lo]; lo < 255 && n <= limit; n += histogram[lo] {
+		lo++
+	}
+	for n := histogram[hi]; hi > 0 && n <= limit; n += histogram[hi] {
+		hi--
+	}
+	if hi <= lo {
+		return img
+	}
+	scale := 255 / float64(hi-lo)
+	stretch := func(v uint8) uint8 {
+		return uint8(math.Max(0, math.Min(255, math.Round((float64(v)-float64(lo))*scale))))
+	}
+	return imaging.AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+		return color.NRGBA{R: stretch(c.R), G: stretch(c.G), B: stretch(c.B), A: c.A}
+	})
+}
+
+// vignette darkens the image towards the corners: by strength at the corners, and not at all in the centre.
+func vignette(img *image.NRGBA, strength float64) *image.NRGBA {
+	out := imaging.Clone(img)
+	bounds := out.Bounds()
+	cx, cy := float64(bounds.Dx())/2, float64(bounds.Dy())/2
+	for y := 0; y < bounds.Dy(); y++ {
+		dy := (float64(y) + 0.5 - cy) / cy
+		for x := 0; x < bounds.Dx(); x++ {
+			dx := (float64(x) + 0.5 - cx) / cx
+			factor := 1 - strength*(dx*dx+dy*dy)/2
+			i := y*out.Stride + x*4
+			for c := 0; c < 3; c++ {
+				out.Pix[i+c] = uint8(math.Round(float64(out.Pix[i+c]) * factor))
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/youtubeanalytics/v2"
+)
+
+const AnalyticsCsvFilename = "./analytics.csv"
+const AnalyticsReportFilename = "./analytics.md"
+
+const analyticsMetrics = "views,estimatedMinutesWatched,averageViewDuration,likes,subscribersGained"
+
+type videoStats struct {
+	Views               float64
+	MinutesWatched      float64
+	AverageViewDuration float64 // seconds
+	Likes               float64
+	SubscribersGained   float64
+}
+
+type analyticsRow struct {
+	Item     *GhtVideoData
+	NearRest bool // the day before or after was a zero day
+	Stats    videoStats
+}
+
+type analyticsGroup struct {
+	Name     string
+	Episodes int
+	Stats    videoStats
+}
+
+// analyticsCommand pulls the stats for every GHT episode over the date range, and writes the CSV and Markdown report.
+func analyticsCommand(ctx context.Context, args []string) error {
+	start := GhtStartTime
+	end := time.Now()
+	if len(args) > 0 {
+		t, err := time.Parse("2006-01-02", args[0])
+		if err != nil {
+			return fmt.Errorf("parsing start date: %w", err)
+		}
+		start = t
+	}
+	if len(args) > 1 {
+		t, err := time.Parse("2006-01-02", args[1])
+		if err != nil {
+			return fmt.Errorf("parsing end date: %w", err)
+		}
+		end = t
+	}
+
+	data, err := getGhtData()
+	if err != nil {
+		return fmt.Errorf("can't load days: %w", err)
+	}
+
+	youtubeService, err := getYoutubeService(ctx)
+	if err != nil {
+		return fmt.Errorf("getting youtube service: %w", err)
+	}
+
+	state, err := loadState()
+	if err != nil {
+		return fmt.Errorf("loading state: %w", err)
+	}
+
+	if err := getGhtVideos(youtubeService, state, data); err != nil {
+		return fmt.Errorf("getting videos: %w", err)
+	}
+
+	if err := ghtUpdateAllStrings(data); err != nil {
+		return fmt.Errorf("updating all strings: %w", err)
+	}
+
+	analyticsService, err := getAnalyticsService(ctx)
+	if err != nil {
+		return fmt.Errorf("getting analytics service: %w", err)
+	}
+
+	var videoIds []string
+	for _, item := range data {
+		if item.Type == "day" && item.Video != nil {
+			videoIds = append(videoIds, item.Video.Id)
+		}
+	}
+
+	stats, err := queryVideoStats(analyticsService, videoIds, start, end)
+	if err != nil {
+		return fmt.Errorf("querying analytics: %w", err)
+	}
+
+	rows := getAnalyticsRows(data, stats)
+
+	csvFile, err := os.Create(AnalyticsCsvFilename)
+	if err != nil {
+		return fmt.Errorf("creating csv file: %w", err)
+	}
+	defer csvFile.Close()
+	if err := writeAnalyticsCsv(csvFile, rows); err != nil {
+		return fmt.Errorf("writing csv: %w", err)
+	}
+
+	reportFile, err := os.Create(AnalyticsReportFilename)
+	if err != nil {
+		return fmt.Errorf("creating report file: %w", err)
+	}
+	defer reportFile.Close()
+	if err := writeAnalyticsReport(reportFile, rows, start, end); err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+
+	fmt.Printf("Wrote stats for %d episodes to %s and %s\n", len(rows), AnalyticsCsvFilename, AnalyticsReportFilename)
+	return nil
+}
+
+// queryVideoStats gets the stats for each video over the date range, by video ID. Videos with no views in the
+// range aren't returned by the API, so they're missing from the map.
+func queryVideoStats(srv *youtubeanalytics.Service, videoIds []string, start, end time.Time) (map[string]videoStats, error) {
+	stats := map[string]videoStats{}
+	for len(videoIds) > 0 {
+		batch := videoIds
+		if len(batch) > 200 {
+			batch = batch[:200]
+		}
+		videoIds = videoIds[len(batch):]
+
+		response, err := srv.Reports.Query().
+			Ids("channel==MINE").
+			StartDate(start.Format("2006-01-02")).
+			EndDate(end.Format("2006-01-02")).
+			Metrics(analyticsMetrics).
+			Dimensions("video").
+			Filters("video==" + strings.Join(batch, ",")).
+			Sort("-views").
+			MaxResults(200).
+			Do()
+		if err != nil {
+			return nil, fmt.Errorf("youtube analytics query call: %w", err)
+		}
+
+		columns := map[string]int{}
+		for i, header := range response.ColumnHeaders {
+			columns[header.Name] = i
+		}
+		for _, name := range append([]string{"video"}, strings.Split(analyticsMetrics, ",")...) {
+			if _, ok := columns[name]; !ok {
+				return nil, fmt.Errorf("no %s column in analytics response", name)
+			}
+		}
+		number := func(row []interface{}, name string) float64 {
+			f, _ := row[columns[name]].(float64)
+			return f
+		}
+		for _, row := range response.Rows {
+			id, _ := row[columns["video"]].(string)
+			stats[id] = videoStats{
+				Views:               number(row, "views"),
+				MinutesWatched:      number(row, "estimatedMinutesWatched"),
+				AverageViewDuration: number(row, "averageViewDuration"),
+				Likes:               number(row, "likes"),
+				SubscribersGained:   number(row, "subscribersGained"),
+			}
+		}
+	}
+	return stats, nil
+}
+
+// getAnalyticsRows joins the stats to the GHT days that have videos.
+func getAnalyticsRows(data []*GhtVideoData, stats map[string]videoStats) []*analyticsRow {
+	var days []*GhtVideoData
+	for _, item := range data {
+		if item.Expedition == "ght" && item.Type == "day" {
+			days = append(days, item)
+		}
+	}
+	var rows []*analyticsRow
+	for i, item := range days {
+		if item.Video == nil {
+			continue
+		}
+		row := &analyticsRow{
+			Item:  item,
+			Stats: stats[item.Video.Id],
+		}
+		if i > 0 && days[i-1].Rest != "" {
+			row.NearRest = true
+		}
+		if i < len(days)-1 && days[i+1].Rest != "" {
+			row.NearRest = true
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// aggregateAnalytics sums the stats by group, keeping the groups in the order they first appear. The average view
+// duration is weighted by views.
+func aggregateAnalytics(rows []*analyticsRow, group func(*analyticsRow) string) []*analyticsGroup {
+	var groups []*analyticsGroup
+	byName := map[string]*analyticsGroup{}
+	for _, row := range rows {
+		name := group(row)
+		g, ok := byName[name]
+		if !ok {
+			g = &analyticsGroup{Name: name}
+			byName[name] = g
+			groups = append(groups, g)
+		}
+		g.Episodes++
+		g.Stats.Views += row.Stats.Views
+		g.Stats.MinutesWatched += row.Stats.MinutesWatched
+		g.Stats.Likes += row.Stats.Likes
+		g.Stats.SubscribersGained += row.Stats.SubscribersGained
+	}
+	for _, g := range groups {
+		if g.Stats.Views > 0 {
+			g.Stats.AverageViewDuration = g.Stats.MinutesWatched * 60 / g.Stats.Views
+		}
+	}
+	return groups
+}
+
+func writeAnalyticsCsv(w io.Writer, rows []*analyticsRow) error {
+	c := csv.NewWriter(w)
+	if err := c.Write([]string{"key", "section", "title", "video_id", "published", "day_of_week", "near_rest_day", "views", "minutes_watched", "average_view_duration", "likes", "subscribers_gained"}); err != nil {
+		return fmt.Errorf("writing csv header: %w", err)
+	}
+	for _, row := range rows {
+		record := []string{
+			fmt.Sprint(row.Item.Key),
+			row.Item.Section,
+			row.Item.FullTitle,
+			row.Item.Video.Id,
+			row.Item.LiveTime.Format("2006-01-02"),
+			row.Item.LiveTime.Weekday().String(),
+			fmt.Sprint(row.NearRest),
+			fmt.Sprintf("%.0f", row.Stats.Views),
+			fmt.Sprintf("%.0f", row.Stats.MinutesWatched),
+			fmt.Sprintf("%.0f", row.Stats.AverageViewDuration),
+			fmt.Sprintf("%.0f", row.Stats.Likes),
+			fmt.Sprintf("%.0f", row.Stats.SubscribersGained),
+		}
+		if err := c.Write(record); err != nil {
+			return fmt.Errorf("writing csv record: %w", err)
+		}
+	}
+	c.Flush()
+	return c.Error()
+}
+
+func writeAnalyticsReport(w io.Writer, rows []*analyticsRow, start, end time.Time) error {
+	var sb strings.Builder
+
+	table := func(title, column string, groups []*analyticsGroup) {
+		sb.WriteString(fmt.Sprintf("\n## %s\n\n", title))
+		sb.WriteString(fmt.Sprintf("| %s | Episodes | Views | Views per episode | Watch time (hours) | Average view duration | Likes | Subscribers gained |\n", column))
+		sb.WriteString("|---|---:|---:|---:|---:|---:|---:|---:|\n")
+		for _, g := range groups {
+			sb.WriteString(fmt.Sprintf("| %s | %d | %.0f | %.0f | %.1f | %s | %.0f | %.0f |\n",
+				g.Name,
+				g.Episodes,
+				g.Stats.Views,
+				g.Stats.Views/float64(g.Episodes),
+				g.Stats.MinutesWatched/60,
+				time.Duration(g.Stats.AverageViewDuration)*time.Second,
+				g.Stats.Likes,
+				g.Stats.SubscribersGained,
+			))
+		}
+	}
+
+	sb.WriteString("# Great Himalaya Trail analytics\n\n")
+	sb.WriteString(fmt.Sprintf("From %s to %s, %d episodes.\n", start.Format("2 January 2006"), end.Format("2 January 2006"), len(rows)))
+
+	table("Total", "", aggregateAnalytics(rows, func(*analyticsRow) string { return "All episodes" }))
+
+	table("By section", "Section", aggregateAnalytics(rows, func(row *analyticsRow) string { return row.Item.Section }))
+
+	table("By rest day", "", aggregateAnalytics(rows, func(row *analyticsRow) string {
+		if row.NearRest {
+			return "Next to a rest day"
+		}
+		return "Not next to a rest day"
+	}))
+
+	days := aggregateAnalytics(rows, func(row *analyticsRow) string { return row.Item.LiveTime.Weekday().String() })
+	weekday := func(name string) int {
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			if d.String() == name {
+				return int(d)
+			}
+		}
+		return 0
+	}
+	sort.Slice(days, func(i, j int) bool { return weekday(days[i].Name) < weekday(days[j].Name) })
+	table("By day of the week published", "Day", days)
+
+	top := append([]*analyticsRow{}, rows...)
+	sort.SliceStable(top, func(i, j int) bool { return top[i].Stats.Views > top[j].Stats.Views })
+	if len(top) > 10 {
+		top = top[:10]
+	}
+	sb.WriteString("\n## Top episodes\n\n")
+	sb.WriteString("| Day | Title | Views | Average view duration |\n")
+	sb.WriteString("|---:|---|---:|---:|\n")
+	for _, row := range top {
+		sb.WriteString(fmt.Sprintf("| %d | [%s](https://youtu.be/%s) | %.0f | %s |\n",
+			row.Item.Key,
+			row.Item.FullTitle,
+			row.Item.Video.Id,
+			row.Stats.Views,
+			time.Duration(row.Stats.AverageViewDuration)*time.Second,
+		))
+	}
+
+	if _, err := io.WriteString(w, sb.String()); err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+	return nil
+}
+
+func getAnalyticsService(ctx context.Context) (*youtubeanalytics.Service, error) {
+
+	var filename string
+	if isLocal() {
+		filename = "/Users/dave/.credentials/youtube_secret.json"
+	} else {
+		filename = "/root/.credentials/youtube_secret.json"
+	}
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("reading client secret file: %w", err)
+	}
+
+	config, err := google.ConfigFromJSON(b, youtubeanalytics.YtAnalyticsReadonlyScope)
+	if err != nil {
+		return nil, fmt.Errorf("parsing client secret file to config: %w", err)
+	}
+
+	client, err := getYoutubeClient(ctx, config, "analytics_token.json")
+	if err != nil {
+		return nil, fmt.Errorf("getting analytics client: %w", err)
+	}
+
+	service, err := youtubeanalytics.New(client)
+	if err != nil {
+		return nil, fmt.Errorf("creating analytics service: %w", err)
+	}
+
+	return service, nil
+}
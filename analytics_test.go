@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/option"
+	"google.golang.org/api/youtube/v3"
+	"google.golang.org/api/youtubeanalytics/v2"
+)
+
+// analyticsStandIn serves a recorded Analytics API response.
+func analyticsStandIn(t *testing.T) *youtubeanalytics.Service {
+	recorded, err := ioutil.ReadFile("./testdata/analytics_query.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("ids") != "channel==MINE" || q.Get("dimensions") != "video" || q.Get("metrics") != analyticsMetrics {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		if q.Get("startDate") != "2020-02-01" || q.Get("endDate") != "2020-03-01" {
+			t.Errorf("unexpected date range %s to %s", q.Get("startDate"), q.Get("endDate"))
+		}
+		if !strings.HasPrefix(q.Get("filters"), "video==") {
+			t.Errorf("unexpected filters %q", q.Get("filters"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(recorded)
+	}))
+	t.Cleanup(server.Close)
+	srv, err := youtubeanalytics.NewService(context.Background(), option.WithEndpoint(server.URL+"/"), option.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+func TestAnalytics(t *testing.T) {
+	data, err := getGhtData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, item := range data {
+		if item.Type == "day" && item.Key <= 3 {
+			item.Video = &youtube.Video{Id: map[int]string{1: "M7EAxcwILRQ", 2: "KDEIibvNGXE", 3: "hRM0UJkTOmA"}[item.Key]}
+			ids = append(ids, item.Video.Id)
+		}
+	}
+
+	stats, err := queryVideoStats(analyticsStandIn(t), ids, time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats["M7EAxcwILRQ"].Views != 1200 || stats["hRM0UJkTOmA"].SubscribersGained != 1 {
+		t.Fatalf("unexpected stats %v", stats)
+	}
+
+	rows := getAnalyticsRows(data, stats)
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(rows))
+	}
+
+	groups := aggregateAnalytics(rows, func(row *analyticsRow) string { return row.Item.Section })
+	if len(groups) != 1 || groups[0].Name != "Kanchenjunga" || groups[0].Episodes != 3 {
+		t.Fatalf("unexpected groups %v", groups)
+	}
+	if groups[0].Stats.Views != 2400 || groups[0].Stats.AverageViewDuration != 15000*60/2400 {
+		t.Fatalf("unexpected group stats %v", groups[0].Stats)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := writeAnalyticsCsv(buf, rows); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 4 {
+		t.Fatalf("expected 4 csv lines, got %d", len(lines))
+	}
+
+	buf = &bytes.Buffer{}
+	if err := writeAnalyticsReport(buf, rows, time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "| Kanchenjunga | 3 | 2400 |") {
+		t.Fatalf("section missing from report:\n%s", buf.String())
+	}
+}
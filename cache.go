@@ -0,0 +1,212 @@
+package main
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"golang.org/x/net/context"
+)
+
+// Drive downloads are kept in CacheDir, keyed by file ID and MD5 checksum, so a changed file is downloaded again. When
+// the cache grows past CacheMaxBytes the least recently used files are removed. Files bigger than the limit aren't
+// cached. Set CacheDir to "" to turn the cache off. They're variables so the tests can use a temporary folder.
+var CacheDir = "./cache"
+var CacheMaxBytes int64 = 50 * 1024 * 1024 * 1024
+
+// cacheEntry is a cached file: CacheDir/<file ID>-<md5>/<name>. The modification time is set when it's used.
+type cacheEntry struct {
+	Key  string
+	Name string
+	Size int64
+	Used time.Time
+}
+
+func cacheKey(file *mediaFile) string {
+	return file.Id + "-" + file.Md5
+}
+
+// openCached returns the file from the cache, downloading it with download first if it's not there. Files without a
+// checksum, or too big to cache, are returned straight from download. Cached files are checked against the checksum as
+// they're read, so a file damaged on disk is never uploaded.
+func openCached(file *mediaFile, download func() (io.ReadCloser, error)) (io.ReadCloser, error) {
+	if CacheDir == "" || file.Md5 == "" || file.Size > CacheMaxBytes {
+		return download()
+	}
+	fname := filepath.Join(CacheDir, cacheKey(file), file.Name)
+	if f, err := os.Open(fname); err == nil {
+		now := time.Now()
+		if err := os.Chtimes(fname, now, now); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("touching cached file: %w", err)
+		}
+		return &verifyingReader{ReadCloser: f, file: file, hash: md5.New()}, nil
+	}
+
+	if err := pruneCache(CacheMaxBytes - file.Size); err != nil {
+		return nil, err
+	}
+	fmt.Printf("Downloading %s (%s) to the cache\n", file.Name, humanize.IBytes(uint64(file.Size)))
+	if err := downloadToCache(file, fname, download); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, fmt.Errorf("opening cached file: %w", err)
+	}
+	return &verifyingReader{ReadCloser: f, file: file, hash: md5.New()}, nil
+}
+
+// downloadToCache writes the download to a temporary file in the cache, verifying the checksum, and only moves it into
+// place when it's complete.
+func downloadToCache(file *mediaFile, fname string, download func() (io.ReadCloser, error)) error {
+	if err := os.MkdirAll(filepath.Dir(fname), 0777); err != nil {
+		return fmt.Errorf("creating cache folder: %w", err)
+	}
+	r, err := download()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if _, ok := r.(*verifyingReader); !ok {
+		r = &verifyingReader{ReadCloser: r, file: file, hash: md5.New()}
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(fname), ".download-")
+	if err != nil {
+		return fmt.Errorf("creating cache file: %w", err)
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("downloading %q: %w", file.Name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("writing cache file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), fname); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("moving cache file: %w", err)
+	}
+	return nil
+}
+
+// getCacheEntries lists the cached files, most recently used first.
+func getCacheEntries() ([]*cacheEntry, error) {
+	dirs, err := ioutil.ReadDir(CacheDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading cache folder: %w", err)
+	}
+	var entries []*cacheEntry
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		files, err := ioutil.ReadDir(filepath.Join(CacheDir, dir.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading cache folder: %w", err)
+		}
+		for _, f := range files {
+			// skip downloads that were interrupted
+			if f.IsDir() || strings.HasPrefix(f.Name(), ".") {
+				continue
+			}
+			entries = append(entries, &cacheEntry{Key: dir.Name(), Name: f.Name(), Size: f.Size(), Used: f.ModTime()})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Used.After(entries[j].Used) })
+	return entries, nil
+}
+
+// pruneCache removes the least recently used files until the cache is no bigger than max, and any folders left
+// empty by interrupted downloads.
+func pruneCache(max int64) error {
+	entries, err := getCacheEntries()
+	if err != nil {
+		return err
+	}
+	var total int64
+	keep := map[string]bool{}
+	for _, e := range entries {
+		total += e.Size
+		if total > max {
+			fmt.Printf("Removing %s (%s) from the cache\n", e.Name, humanize.IBytes(uint64(e.Size)))
+			continue
+		}
+		keep[e.Key] = true
+	}
+	dirs, err := ioutil.ReadDir(CacheDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading cache folder: %w", err)
+	}
+	for _, dir := range dirs {
+		if dir.IsDir() && !keep[dir.Name()] {
+			if err := os.RemoveAll(filepath.Join(CacheDir, dir.Name())); err != nil {
+				return fmt.Errorf("removing from cache: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// cacheCommand lists the cached downloads, or with prune removes the least recently used down to a size (the limit
+// by default, "0" to empty it).
+func cacheCommand(ctx context.Context, args []string) error {
+	if CacheDir == "" {
+		return fmt.Errorf("the cache is turned off (CacheDir is empty)")
+	}
+	action := "list"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	switch action {
+	case "list":
+		if len(args) > 1 {
+			return fmt.Errorf("unexpected argument %q", args[1])
+		}
+		entries, err := getCacheEntries()
+		if err != nil {
+			return err
+		}
+		var total int64
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSIZE\tUSED\tKEY")
+		for _, e := range entries {
+			total += e.Size
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.Name, humanize.IBytes(uint64(e.Size)), humanize.Time(e.Used), e.Key)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		fmt.Printf("%d files, %s of %s\n", len(entries), humanize.IBytes(uint64(total)), humanize.IBytes(uint64(CacheMaxBytes)))
+		return nil
+	case "prune":
+		max := CacheMaxBytes
+		if len(args) > 2 {
+			return fmt.Errorf("unexpected argument %q", args[2])
+		}
+		if len(args) > 1 {
+			b, err := humanize.ParseBytes(args[1])
+			if err != nil {
+				return fmt.Errorf("parsing size %q: %w", args[1], err)
+			}
+			max = int64(b)
+		}
+		return pruneCache(max)
+	}
+	return fmt.Errorf("usage: cache [list|prune [size]]")
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCache points the cache at a temporary folder for the test.
+func testCache(t *testing.T, max int64) string {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldDir, oldMax := CacheDir, CacheMaxBytes
+	CacheDir, CacheMaxBytes = filepath.Join(dir, "cache"), max
+	t.Cleanup(func() {
+		CacheDir, CacheMaxBytes = oldDir, oldMax
+		os.RemoveAll(dir)
+	})
+	return CacheDir
+}
+
+// fakeDrive serves file contents by ID, counting the downloads.
+type fakeDrive struct {
+	contents  map[string]string
+	downloads int
+}
+
+func (d *fakeDrive) file(id, name string) *mediaFile {
+	sum := md5.Sum([]byte(d.contents[id]))
+	return &mediaFile{Id: id, Name: name, Size: int64(len(d.contents[id])), Md5: hex.EncodeToString(sum[:])}
+}
+
+func (d *fakeDrive) download(file *mediaFile) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		d.downloads++
+		return ioutil.NopCloser(bytes.NewBufferString(d.contents[file.Id])), nil
+	}
+}
+
+func readCached(t *testing.T, d *fakeDrive, file *mediaFile) string {
+	r, err := openCached(file, d.download(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func cachedNames(t *testing.T) []string {
+	entries, err := getCacheEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+func TestCacheHit(t *testing.T) {
+	testCache(t, 1000)
+	d := &fakeDrive{contents: map[string]string{"1": "first photo"}}
+	file := d.file("1", "D001.jpg")
+	for i := 0; i < 3; i++ {
+		if got := readCached(t, d, file); got != "first photo" {
+			t.Fatalf("read %q", got)
+		}
+	}
+	if d.downloads != 1 {
+		t.Errorf("downloaded %d times, expected once", d.downloads)
+	}
+}
+
+func TestCacheChangedFile(t *testing.T) {
+	testCache(t, 1000)
+	d := &fakeDrive{contents: map[string]string{"1": "first photo"}}
+	readCached(t, d, d.file("1", "D001.jpg"))
+
+	// the same file ID with new contents has a new checksum, so it's a miss
+	d.contents["1"] = "edited photo"
+	if got := readCached(t, d, d.file("1", "D001.jpg")); got != "edited photo" {
+		t.Errorf("read %q, expected the new contents", got)
+	}
+	if d.downloads != 2 {
+		t.Errorf("downloaded %d times, expected twice", d.downloads)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	testCache(t, 25)
+	d := &fakeDrive{contents: map[string]string{"a": "aaaaaaaaaa", "b": "bbbbbbbbbb", "c": "cccccccccc"}}
+	a, b, c := d.file("a", "a.jpg"), d.file("b", "b.jpg"), d.file("c", "c.jpg")
+	readCached(t, d, a)
+	readCached(t, d, b)
+
+	// a was used more recently than b
+	now := time.Now()
+	for file, used := range map[*mediaFile]time.Time{a: now.Add(-time.Minute), b: now.Add(-time.Hour)} {
+		fname := filepath.Join(CacheDir, cacheKey(file), file.Name)
+		if err := os.Chtimes(fname, used, used); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// there's only room for two, so b goes
+	readCached(t, d, c)
+	if got := cachedNames(t); len(got) != 2 || got[0] != "c.jpg" || got[1] != "a.jpg" {
+		t.Errorf("cache has %v, expected [c.jpg a.jpg]", got)
+	}
+
+	readCached(t, d, a)
+	if d.downloads != 3 {
+		t.Errorf("downloaded %d times, expected 3", d.downloads)
+	}
+	readCached(t, d, b)
+	if d.downloads != 4 {
+		t.Errorf("downloaded %d times, expected b to be downloaded again", d.downloads)
+	}
+}
+
+// failingReader returns some of the file, then an error, like a dropped connection.
+type failingReader struct {
+	r io.Reader
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err == io.EOF {
+		return n, errors.New("connection reset")
+	}
+	return n, err
+}
+
+func TestCacheInterruptedDownload(t *testing.T) {
+	dir := testCache(t, 1000)
+	d := &fakeDrive{contents: map[string]string{"1": "first photo"}}
+	file := d.file("1", "D001.jpg")
+
+	for name, download := range map[string]func() (io.ReadCloser, error){
+		"dropped": func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(&failingReader{r: bytes.NewBufferString("first")}), nil
+		},
+		"truncated": func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewBufferString("first")), nil
+		},
+		"corrupt": func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewBufferString("first phot0")), nil
+		},
+	} {
+		if _, err := openCached(file, download); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+		if got := cachedNames(t); len(got) != 0 {
+			t.Errorf("%s: cache has %v, expected nothing", name, got)
+		}
+		leftover, err := ioutil.ReadDir(filepath.Join(dir, cacheKey(file)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(leftover) != 0 {
+			t.Errorf("%s: %d files left in the cache folder", name, len(leftover))
+		}
+	}
+
+	// the next try downloads it properly
+	if got := readCached(t, d, file); got != "first photo" {
+		t.Errorf("read %q", got)
+	}
+}
+
+func TestCacheDamagedFile(t *testing.T) {
+	testCache(t, 1000)
+	d := &fakeDrive{contents: map[string]string{"1": "first photo"}}
+	file := d.file("1", "D001.jpg")
+	readCached(t, d, file)
+
+	// the same size, so only the checksum catches it
+	fname := filepath.Join(CacheDir, cacheKey(file), file.Name)
+	if err := ioutil.WriteFile(fname, []byte("first phot0"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	r, err := openCached(file, d.download(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := ioutil.ReadAll(r); err == nil {
+		t.Error("expected an error reading the damaged file")
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// Caption files live in their own Drive folder (or the captions directory of a local media source), named like the
+// videos with a language suffix: D012.en.srt, D012.en-US.vtt etc. Leave the folder empty to skip captions for that
+// expedition.
+const GhtCaptionFolder = ""
+const AntCaptionFolder = ""
+
+var CaptionParts = []string{"id", "snippet"}
+
+var captionFilenameRegex = regexp.MustCompile(`^[A-Z][0-9]+[^.]*\.([A-Za-z]{2,3}(?:-[A-Za-z]{2,4})?)\.(srt|vtt)$`)
+var captionTimingRegex = regexp.MustCompile(`^(\S+)\s+-->\s+(\S+)`)
+
+type captionCue struct {
+	Start, End time.Duration
+	Text       string
+}
+
+// CaptionState records an uploaded caption track, so we only upload it again when the file changes.
+type CaptionState struct {
+	Id     string
+	Hash   string
+	Source string // MD5 of the caption file, if the media source has one, so an unchanged file isn't even downloaded
+}
+
+// parseCaptionFilename returns the language and format (srt or vtt) from a caption filename.
+func parseCaptionFilename(name string) (language, format string, err error) {
+	matches := captionFilenameRegex.FindStringSubmatch(name)
+	if len(matches) != 3 {
+		return "", "", fmt.Errorf("caption filename %q should look like D012.en.srt", name)
+	}
+	return matches[1], matches[2], nil
+}
+
+// parseCaptions parses an SRT or WebVTT file. The formats are close enough that the same parser handles both: cues
+// are separated by blank lines, and the timing line may be preceded by a cue number / identifier.
+func parseCaptions(r io.Reader, format string) ([]captionCue, error) {
+	var cues []captionCue
+	var block []string
+
+	flush := func() error {
+		lines := block
+		block = nil
+		if len(lines) == 0 {
+			return nil
+		}
+		if format == "vtt" {
+			switch {
+			case strings.HasPrefix(lines[0], "WEBVTT"),
+				strings.HasPrefix(lines[0], "NOTE"),
+				strings.HasPrefix(lines[0], "STYLE"),
+				strings.HasPrefix(lines[0], "REGION"):
+				return nil
+			}
+		}
+		timing := -1
+		for i, line := range lines {
+			if captionTimingRegex.MatchString(line) {
+				timing = i
+				break
+			}
+		}
+		if timing == -1 || timing > 1 {
+			return fmt.Errorf("no timing line in cue %q", strings.Join(lines, " / "))
+		}
+		matches := captionTimingRegex.FindStringSubmatch(lines[timing])
+		start, err := parseCaptionTime(matches[1])
+		if err != nil {
+			return err
+		}
+		end, err := parseCaptionTime(matches[2])
+		if err != nil {
+			return err
+		}
+		cues = append(cues, captionCue{
+			Start: start,
+			End:   end,
+			Text:  strings.Join(lines[timing+1:], "\n"),
+		})
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	first := true
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff") // byte order mark
+			first = false
+		}
+		if line == "" {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		block = append(block, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading captions: %w", err)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return cues, nil
+}
+
+// parseCaptionTime parses 01:02:03,456 (SRT), 01:02:03.456 or 02:03.456 (WebVTT).
+func parseCaptionTime(s string) (time.Duration, error) {
+	parts := strings.Split(strings.Replace(s, ",", ".", 1), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid caption time %q", s)
+	}
+	var hours int
+	if len(parts) == 3 {
+		h, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return 0, fmt.Errorf("invalid caption time %q", s)
+		}
+		hours = h
+		parts = parts[1:]
+	}
+	minutes, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid caption time %q", s)
+	}
+	seconds, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid caption time %q", s)
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds*float64(time.Second)).Round(time.Millisecond), nil
+}
+
+// validateCaptions checks the cues are in order and don't overlap, and lists every problem found.
+func validateCaptions(cues []captionCue) error {
+	if len(cues) == 0 {
+		return fmt.Errorf("no cues found")
+	}
+	var problems []string
+	for i, cue := range cues {
+		if cue.End <= cue.Start {
+			problems = append(problems, fmt.Sprintf("cue %d ends before it starts", i+1))
+		}
+		if strings.TrimSpace(cue.Text) == "" {
+			problems = append(problems, fmt.Sprintf("cue %d is empty", i+1))
+		}
+		if i == 0 {
+			continue
+		}
+		prev := cues[i-1]
+		if cue.Start < prev.Start {
+			problems = append(problems, fmt.Sprintf("cue %d starts before cue %d", i+1, i))
+		} else if cue.Start < prev.End {
+			problems = append(problems, fmt.Sprintf("cue %d overlaps cue %d by %v", i+1, i, prev.End-cue.Start))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid captions: %s", strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// formatCaptions writes the cues as a normalized SRT file: renumbered, with LF line endings and no trailing spaces.
+func formatCaptions(cues []captionCue) []byte {
+	format := func(d time.Duration) string {
+		ms := d.Milliseconds()
+		return fmt.Sprintf("%02d:%02d:%02d,%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+	}
+	buf := bytes.NewBufferString("")
+	for i, cue := range cues {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(buf, "%d\n%s --> %s\n%s\n", i+1, format(cue.Start), format(cue.End), cue.Text)
+	}
+	return buf.Bytes()
+}
+
+// syncCaptions uploads the caption files for a video. Tracks that haven't changed since the last upload are skipped,
+// and existing tracks in the same language are updated rather than duplicated.
+func syncCaptions(youtubeService *youtube.Service, media mediaSource, state *State, videoId string, files []*mediaFile) error {
+
+	var existing []*youtube.Caption
+
+	for _, file := range files {
+		language, format, err := parseCaptionFilename(file.Name)
+		if err != nil {
+			return err
+		}
+
+		key := videoId + "/" + language
+		previous := state.Captions[key]
+		if file.Md5 != "" && previous.Source == file.Md5 {
+			continue
+		}
+
+		download, err := media.Open(file)
+		if err != nil {
+			return fmt.Errorf("opening caption file: %w", err)
+		}
+		cues, err := parseCaptions(download, format)
+		download.Close()
+		if err != nil {
+			return fmt.Errorf("parsing %q: %w", file.Name, err)
+		}
+		if err := validateCaptions(cues); err != nil {
+			return fmt.Errorf("validating %q: %w", file.Name, err)
+		}
+		normalized := formatCaptions(cues)
+		sum := sha256.Sum256(normalized)
+		hash := hex.EncodeToString(sum[:])
+
+		if previous.Hash == hash {
+			if previous.Source != file.Md5 {
+				previous.Source = file.Md5
+				state.Captions[key] = previous
+				if err := state.Save(); err != nil {
+					return fmt.Errorf("saving state: %w", err)
+				}
+			}
+			continue
+		}
+
+		caption := &youtube.Caption{
+			Id: previous.Id,
+			Snippet: &youtube.CaptionSnippet{
+				VideoId:  videoId,
+				Language: language,
+			},
+		}
+
+		if caption.Id == "" {
+			// the track may have been uploaded by hand in Studio
+			if existing == nil {
+				response, err := youtubeService.Captions.List(videoId, CaptionParts).Do()
+				if err != nil {
+					return fmt.Errorf("youtube captions list call: %w", err)
+				}
+				existing = response.Items
+			}
+			for _, c := range existing {
+				if c.Snippet.Language == language && c.Snippet.TrackKind != "asr" {
+					caption.Id = c.Id
+					break
+				}
+			}
+		}
+
+		if caption.Id == "" {
+			fmt.Printf("Inserting %s captions: %q\n", language, file.Name)
+			inserted, err := youtubeService.Captions.Insert(CaptionParts, caption).Media(bytes.NewReader(normalized)).Do()
+			if err != nil {
+				return fmt.Errorf("inserting captions: %w", err)
+			}
+			caption.Id = inserted.Id
+		} else {
+			fmt.Printf("Updating %s captions: %q\n", language, file.Name)
+			caption.Snippet = nil
+			if _, err := youtubeService.Captions.Update([]string{"id"}, caption).Media(bytes.NewReader(normalized)).Do(); err != nil {
+				return fmt.Errorf("updating captions: %w", err)
+			}
+		}
+
+		state.Captions[key] = CaptionState{Id: caption.Id, Hash: hash, Source: file.Md5}
+		if err := state.Save(); err != nil {
+			return fmt.Errorf("saving state: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCaptionFilename(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		language string
+		format   string
+		err      bool
+	}{
+		{name: "D012.en.srt", language: "en", format: "srt"},
+		{name: "D012.en-US.vtt", language: "en-US", format: "vtt"},
+		{name: "D012_v2.ne.srt", language: "ne", format: "srt"},
+		{name: "T001_kjrsmq.fil.vtt", language: "fil", format: "vtt"},
+		{name: "D012.srt", err: true},
+		{name: "D012.en.txt", err: true},
+		{name: "D012.english.srt", err: true},
+		{name: "day12.en.srt", err: true},
+	} {
+		language, format, err := parseCaptionFilename(test.name)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %q %q", test.name, language, format)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if language != test.language || format != test.format {
+			t.Errorf("%s: got %q %q, expected %q %q", test.name, language, format, test.language, test.format)
+		}
+	}
+}
+
+func TestParseCaptions(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		format string
+		input  string
+		want   []captionCue
+		err    bool
+	}{
+		{
+			name:   "srt",
+			format: "srt",
+			input:  "1\n00:00:01,000 --> 00:00:02,500\nNamaste\n\n2\n00:00:03,000 --> 00:01:04,250\nTwo\nlines\n",
+			want: []captionCue{
+				{Start: time.Second, End: 2500 * time.Millisecond, Text: "Namaste"},
+				{Start: 3 * time.Second, End: time.Minute + 4250*time.Millisecond, Text: "Two\nlines"},
+			},
+		},
+		{
+			name:   "srt with BOM, CRLF and trailing spaces",
+			format: "srt",
+			input:  "\ufeff1\r\n00:00:01,000 --> 00:00:02,000  \r\nHello \r\n\r\n\r\n2\r\n00:00:02,000 --> 00:00:03,000\r\nAgain\r\n",
+			want: []captionCue{
+				{Start: time.Second, End: 2 * time.Second, Text: "Hello"},
+				{Start: 2 * time.Second, End: 3 * time.Second, Text: "Again"},
+			},
+		},
+		{
+			name:   "vtt",
+			format: "vtt",
+			input:  "WEBVTT\n\nNOTE a comment\n\nintro\n00:01.000 --> 00:02.000 align:start\nHello\n\n01:00:00.500 --> 01:00:01.000\nLater\n",
+			want: []captionCue{
+				{Start: time.Second, End: 2 * time.Second, Text: "Hello"},
+				{Start: time.Hour + 500*time.Millisecond, End: time.Hour + time.Second, Text: "Later"},
+			},
+		},
+		{
+			name:   "missing timing",
+			format: "srt",
+			input:  "1\nHello\n",
+			err:    true,
+		},
+		{
+			name:   "invalid time",
+			format: "srt",
+			input:  "1\n00:00:xx,000 --> 00:00:02,000\nHello\n",
+			err:    true,
+		},
+		{
+			name:   "vtt header in srt",
+			format: "srt",
+			input:  "WEBVTT\n\n00:01.000 --> 00:02.000\nHello\n",
+			err:    true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cues, err := parseCaptions(strings.NewReader(test.input), test.format)
+			if test.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", cues)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cues, test.want) {
+				t.Errorf("got %v, expected %v", cues, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateCaptions(t *testing.T) {
+	cue := func(start, end int, text string) captionCue {
+		return captionCue{Start: time.Duration(start) * time.Second, End: time.Duration(end) * time.Second, Text: text}
+	}
+	for _, test := range []struct {
+		name string
+		cues []captionCue
+		want string // substring of the error, empty for valid captions
+	}{
+		{name: "valid", cues: []captionCue{cue(1, 2, "a"), cue(2, 3, "b"), cue(5, 6, "c")}},
+		{name: "no cues", want: "no cues"},
+		{name: "ends before start", cues: []captionCue{cue(2, 1, "a")}, want: "cue 1 ends before it starts"},
+		{name: "empty", cues: []captionCue{cue(1, 2, " ")}, want: "cue 1 is empty"},
+		{name: "out of order", cues: []captionCue{cue(5, 6, "a"), cue(1, 2, "b")}, want: "cue 2 starts before cue 1"},
+		{name: "overlap", cues: []captionCue{cue(1, 4, "a"), cue(3, 5, "b")}, want: "cue 2 overlaps cue 1 by 1s"},
+		{name: "every problem", cues: []captionCue{cue(1, 4, ""), cue(3, 2, "b")}, want: "cue 1 is empty, cue 2 ends before it starts, cue 2 overlaps cue 1 by 1s"},
+	} {
+		err := validateCaptions(test.cues)
+		switch {
+		case test.want == "" && err != nil:
+			t.Errorf("%s: %v", test.name, err)
+		case test.want != "" && err == nil:
+			t.Errorf("%s: expected an error", test.name)
+		case test.want != "" && !strings.Contains(err.Error(), test.want):
+			t.Errorf("%s: got %q, expected it to contain %q", test.name, err, test.want)
+		}
+	}
+}
+
+func TestFormatCaptions(t *testing.T) {
+	cues := []captionCue{
+		{Start: 1500 * time.Millisecond, End: 2 * time.Second, Text: "One"},
+		{Start: time.Hour + 2*time.Minute + 3*time.Second + 4*time.Millisecond, End: time.Hour + 2*time.Minute + 4*time.Second, Text: "Two\nlines"},
+	}
+	want := "1\n00:00:01,500 --> 00:00:02,000\nOne\n\n2\n01:02:03,004 --> 01:02:04,000\nTwo\nlines\n"
+	if got := string(formatCaptions(cues)); got != want {
+		t.Errorf("got %q, expected %q", got, want)
+	}
+	parsed, err := parseCaptions(strings.NewReader(want), "srt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, cues) {
+		t.Errorf("round trip got %v, expected %v", parsed, cues)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+)
+
+// runCommand runs a named command, e.g. `go run . analytics 2020-02-01 2020-12-31`. With no command, main falls back
+// to the upload workflow.
+func runCommand(ctx context.Context, command string, args []string) error {
+	switch command {
+	case "analytics":
+		return analyticsCommand(ctx, args)
+	case "comments":
+		return commentsCommand(ctx, args)
+	case "comments-reply":
+		return commentsReplyCommand(ctx, args)
+	case "status":
+		return statusCommand(ctx, args)
+	case "processing":
+		return processingCommand(ctx, args)
+	case "check-thumbnails":
+		return checkThumbnailsCommand(ctx, args)
+	case "renditions":
+		return renditionsCommand(ctx, args)
+	case "review":
+		return reviewCommand(ctx, args)
+	case "cache":
+		return cacheCommand(ctx, args)
+	}
+	return fmt.Errorf("unknown command %q", command)
+}
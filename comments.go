@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/youtube/v3"
+)
+
+const CommentRepliesFilename = "./comment_replies.json"
+const CommentDigestMarkdownFilename = "./comments.md"
+const CommentDigestHtmlFilename = "./comments.html"
+
+var CommentThreadParts = []string{"id", "snippet", "replies"}
+
+// unansweredThread is a comment thread on one of our videos that nobody from the channel has replied to.
+type unansweredThread struct {
+	Episode   *managedEpisode
+	ThreadId  string
+	Author    string
+	Published string
+	Text      string
+	Replies   int64
+}
+
+// CommentReply is an entry in the replies file. The digest writes an entry for each unanswered thread, someone fills
+// in Reply, and the comments-reply command posts them.
+type CommentReply struct {
+	ThreadId string
+	Episode  string
+	Author   string
+	Comment  string
+	Reply    string
+	Posted   bool
+}
+
+// commentsCommand writes a digest of the unanswered comments, as a table on the terminal, or as a Markdown or HTML
+// file, and adds the threads to the replies file.
+func commentsCommand(ctx context.Context, args []string) error {
+	format := "table"
+	if len(args) > 0 {
+		format = args[0]
+	}
+	switch format {
+	case "table", "markdown", "html":
+	default:
+		return fmt.Errorf("unknown format %q, should be table, markdown or html", format)
+	}
+
+	youtubeService, err := getYoutubeService(ctx)
+	if err != nil {
+		return fmt.Errorf("getting youtube service: %w", err)
+	}
+
+	episodes, err := getManagedEpisodes(youtubeService)
+	if err != nil {
+		return fmt.Errorf("getting episodes: %w", err)
+	}
+
+	var threads []*unansweredThread
+	for _, episode := range episodes {
+		t, err := getUnansweredThreads(youtubeService, episode)
+		if isCommentsDisabled(err) {
+			fmt.Printf("Comments are disabled on %s day %d, skipping\n", episode.Expedition, episode.Key)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("getting comments for %s day %d: %w", episode.Expedition, episode.Key, err)
+		}
+		threads = append(threads, t...)
+	}
+
+	switch format {
+	case "table":
+		err = writeCommentTable(os.Stdout, threads)
+	case "markdown":
+		err = writeCommentFile(CommentDigestMarkdownFilename, threads, writeCommentMarkdown)
+	case "html":
+		err = writeCommentFile(CommentDigestHtmlFilename, threads, writeCommentHtml)
+	}
+	if err != nil {
+		return fmt.Errorf("writing digest: %w", err)
+	}
+
+	if err := addCommentReplies(threads); err != nil {
+		return fmt.Errorf("updating replies file: %w", err)
+	}
+
+	fmt.Printf("Found %d unanswered comments on %d episodes\n", len(threads), len(episodes))
+	return nil
+}
+
+// commentsReplyCommand posts the replies that have been filled in in the replies file. With -n, it only prints them.
+func commentsReplyCommand(ctx context.Context, args []string) error {
+	dryRun := len(args) > 0 && (args[0] == "-n" || args[0] == "--dry-run")
+
+	replies, err := loadCommentReplies()
+	if err != nil {
+		return err
+	}
+
+	youtubeService, err := getYoutubeService(ctx)
+	if err != nil {
+		return fmt.Errorf("getting youtube service: %w", err)
+	}
+
+	for _, reply := range replies {
+		if reply.Posted || strings.TrimSpace(reply.Reply) == "" {
+			continue
+		}
+		if dryRun {
+			fmt.Printf("Would reply to %s on %s:\n> %s\n%s\n\n", reply.Author, reply.Episode, reply.Comment, reply.Reply)
+			continue
+		}
+		fmt.Printf("Replying to %s on %s\n", reply.Author, reply.Episode)
+		comment := &youtube.Comment{
+			Snippet: &youtube.CommentSnippet{
+				ParentId:     reply.ThreadId,
+				TextOriginal: reply.Reply,
+			},
+		}
+		if _, err := youtubeService.Comments.Insert([]string{"snippet"}, comment).Do(); err != nil {
+			return fmt.Errorf("inserting comment: %w", err)
+		}
+		reply.Posted = true
+		if err := saveCommentReplies(replies); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isCommentsDisabled is true if listing the comment threads failed because the video has comments turned off.
+func isCommentsDisabled(err error) bool {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusForbidden {
+		return false
+	}
+	for _, item := range apiErr.Errors {
+		if item.Reason == "commentsDisabled" {
+			return true
+		}
+	}
+	return false
+}
+
+// getUnansweredThreads returns the threads on the video with no reply from the channel. Threads started by the
+// channel are ignored.
+func getUnansweredThreads(srv *youtube.Service, episode *managedEpisode) ([]*unansweredThread, error) {
+	var threads []*unansweredThread
+
+	var done bool
+	var pageToken string
+
+	for !done {
+
+		response, err := srv.CommentThreads.List(CommentThreadParts).VideoId(episode.VideoId).TextFormat("plainText").MaxResults(100).PageToken(pageToken).Do()
+		if err != nil {
+			return nil, fmt.Errorf("youtube comment threads list call: %w", err)
+		}
+
+		for _, thread := range response.Items {
+			top := thread.Snippet.TopLevelComment
+			if isChannelComment(top) {
+				continue
+			}
+
+			// only a few replies are included in the thread, so get the rest if needed
+			replies := thread.Replies
+			if replies == nil {
+				replies = &youtube.CommentThreadReplies{}
+			}
+			if int64(len(replies.Comments)) < thread.Snippet.TotalReplyCount {
+				all, err := getReplies(srv, thread.Id)
+				if err != nil {
+					return nil, err
+				}
+				replies.Comments = all
+			}
+
+			var answered bool
+			for _, reply := range replies.Comments {
+				if isChannelComment(reply) {
+					answered = true
+					break
+				}
+			}
+			if answered {
+				continue
+			}
+
+			threads = append(threads, &unansweredThread{
+				Episode:   episode,
+				ThreadId:  thread.Id,
+				Author:    top.Snippet.AuthorDisplayName,
+				Published: top.Snippet.PublishedAt,
+				Text:      top.Snippet.TextDisplay,
+				Replies:   thread.Snippet.TotalReplyCount,
+			})
+		}
+
+		pageToken = response.NextPageToken
+		if pageToken == "" {
+			done = true
+		}
+	}
+	return threads, nil
+}
+
+func getReplies(srv *youtube.Service, threadId string) ([]*youtube.Comment, error) {
+	var all []*youtube.Comment
+
+	var done bool
+	var pageToken string
+
+	for !done {
+
+		response, err := srv.Comments.List([]string{"id", "snippet"}).ParentId(threadId).TextFormat("plainText").MaxResults(100).PageToken(pageToken).Do()
+		if err != nil {
+			return nil, fmt.Errorf("youtube comments list call: %w", err)
+		}
+
+		all = append(all, response.Items...)
+
+		pageToken = response.NextPageToken
+		if pageToken == "" {
+			done = true
+		}
+	}
+	return all, nil
+}
+
+func isChannelComment(c *youtube.Comment) bool {
+	return c.Snippet.AuthorChannelId != nil && c.Snippet.AuthorChannelId.Value == ChannelId
+}
+
+// commentGroups groups the threads by section, then episode, keeping the order of the episodes.
+func commentGroups(threads []*unansweredThread) [][][]*unansweredThread {
+	var sections [][][]*unansweredThread
+	for _, t := range threads {
+		if len(sections) == 0 || sections[len(sections)-1][0][0].Episode.Section != t.Episode.Section {
+			sections = append(sections, nil)
+		}
+		section := sections[len(sections)-1]
+		if len(section) == 0 || section[len(section)-1][0].Episode != t.Episode {
+			section = append(section, nil)
+		}
+		section[len(section)-1] = append(section[len(section)-1], t)
+		sections[len(sections)-1] = section
+	}
+	return sections
+}
+
+func writeCommentTable(w io.Writer, threads []*unansweredThread) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SECTION\tDAY\tAUTHOR\tCOMMENT\tREPLIES")
+	for _, t := range threads {
+		text := strings.Join(strings.Fields(t.Text), " ")
+		if len([]rune(text)) > 80 {
+			text = string([]rune(text)[:77]) + "..."
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%d\n", t.Episode.Section, t.Episode.Key, t.Author, text, t.Replies)
+	}
+	return tw.Flush()
+}
+
+func writeCommentMarkdown(w io.Writer, threads []*unansweredThread) error {
+	var sb strings.Builder
+	sb.WriteString("# Unanswered comments\n")
+	for _, section := range commentGroups(threads) {
+		sb.WriteString(fmt.Sprintf("\n## %s\n", section[0][0].Episode.Section))
+		for _, episode := range section {
+			e := episode[0].Episode
+			sb.WriteString(fmt.Sprintf("\n### [%s](https://youtu.be/%s)\n\n", e.Title, e.VideoId))
+			for _, t := range episode {
+				sb.WriteString(fmt.Sprintf("- **%s** (%s): %s\n", t.Author, t.Published, strings.Join(strings.Fields(t.Text), " ")))
+			}
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+var commentHtmlTemplate = template.Must(template.New("main").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Unanswered comments</title>
+<style>
+body { font-family: sans-serif; max-width: 60rem; margin: 2rem auto; }
+.comment { margin: 0 0 1rem 1rem; }
+.author { font-weight: bold; }
+.date { color: #888; }
+</style>
+</head>
+<body>
+<h1>Unanswered comments</h1>
+{{ range . }}
+<h2>{{ (index (index . 0) 0).Episode.Section }}</h2>
+{{ range . }}{{ with (index . 0).Episode }}
+<h3><a href="https://youtu.be/{{ .VideoId }}">{{ .Title }}</a></h3>
+{{ end }}{{ range . }}
+<div class="comment"><span class="author">{{ .Author }}</span> <span class="date">{{ .Published }}</span> <a href="https://www.youtube.com/watch?v={{ .Episode.VideoId }}&lc={{ .ThreadId }}">link</a><br>{{ .Text }}</div>
+{{ end }}{{ end }}{{ end }}
+</body>
+</html>
+`))
+
+func writeCommentHtml(w io.Writer, threads []*unansweredThread) error {
+	if err := commentHtmlTemplate.Execute(w, commentGroups(threads)); err != nil {
+		return fmt.Errorf("executing comments template: %w", err)
+	}
+	return nil
+}
+
+func writeCommentFile(fname string, threads []*unansweredThread, write func(io.Writer, []*unansweredThread) error) error {
+	f, err := os.Create(fname)
+	if err != nil {
+		return fmt.Errorf("creating %q: %w", fname, err)
+	}
+	defer f.Close()
+	if err := write(f, threads); err != nil {
+		return err
+	}
+	fmt.Println("Wrote", fname)
+	return nil
+}
+
+// addCommentReplies adds the new threads to the replies file, keeping any replies that have already been written.
+func addCommentReplies(threads []*unansweredThread) error {
+	replies, err := loadCommentReplies()
+	if err != nil {
+		return err
+	}
+	existing := map[string]bool{}
+	for _, reply := range replies {
+		existing[reply.ThreadId] = true
+	}
+	for _, t := range threads {
+		if existing[t.ThreadId] {
+			continue
+		}
+		replies = append(replies, &CommentReply{
+			ThreadId: t.ThreadId,
+			Episode:  t.Episode.Title,
+			Author:   t.Author,
+			Comment:  t.Text,
+		})
+	}
+	return saveCommentReplies(replies)
+}
+
+func loadCommentReplies() ([]*CommentReply, error) {
+	var replies []*CommentReply
+	raw, err := ioutil.ReadFile(CommentRepliesFilename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading replies file: %w", err)
+	}
+	if err := json.Unmarshal(raw, &replies); err != nil {
+		return nil, fmt.Errorf("parsing replies file: %w", err)
+	}
+	return replies, nil
+}
+
+func saveCommentReplies(replies []*CommentReply) error {
+	raw, err := json.MarshalIndent(replies, "", "\t")
+	if err != nil {
+		return fmt.Errorf("encoding replies: %w", err)
+	}
+	if err := ioutil.WriteFile(CommentRepliesFilename, raw, 0666); err != nil {
+		return fmt.Errorf("writing replies file: %w", err)
+	}
+	return nil
+}
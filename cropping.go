@@ -0,0 +1,273 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// Crop strategies: an anchor (center, top, bottom-left etc.) keeps that part of the photo, a focal point "x,y" (as
+// fractions of the width and height, e.g. "0.5,0.2") keeps the crop as close to centred on it as possible, and
+// entropy, edges and thirds choose automatically. The fit strategy doesn't crop: the photo is resized to fit inside
+// the canvas, keeping its aspect ratio.
+var cropAnchors = map[string]imaging.Anchor{
+	"center":       imaging.Center,
+	"top":          imaging.Top,
+	"bottom":       imaging.Bottom,
+	"left":         imaging.Left,
+	"right":        imaging.Right,
+	"top-left":     imaging.TopLeft,
+	"top-right":    imaging.TopRight,
+	"bottom-left":  imaging.BottomLeft,
+	"bottom-right": imaging.BottomRight,
+}
+
+// The automatic strategies work on a copy of the photo scaled down to this size on the long side.
+const cropAnalysisSize = 200
+
+// CropState records the crop box chosen for a thumbnail, so it doesn't change between renders. It's only reused while
+// the strategy and the size of the photo are the same.
+type CropState struct {
+	Strategy      string
+	Width, Height int
+	Box           image.Rectangle
+}
+
+// cropThumbnail crops the photo to the aspect ratio of the canvas and resizes it. Anchors use imaging.Fill, and the
+// other strategies choose a box in the photo, which is recorded in saved (if not nil).
+func cropThumbnail(img image.Image, strategy string, width, height int, saved *CropState) (*image.NRGBA, error) {
+	if strategy == "" {
+		strategy = "center"
+	}
+	if strategy == "fit" {
+		return imaging.Fit(img, width, height, imaging.Lanczos), nil
+	}
+	if anchor, ok := cropAnchors[strategy]; ok {
+		return imaging.Fill(img, width, height, anchor, imaging.Lanczos), nil
+	}
+
+	size := img.Bounds().Size()
+	if saved != nil && saved.Strategy == strategy && saved.Width == size.X && saved.Height == size.Y && !saved.Box.Empty() {
+		return imaging.Resize(imaging.Crop(img, saved.Box), width, height, imaging.Lanczos), nil
+	}
+
+	box, err := chooseCrop(img, strategy, width, height)
+	if err != nil {
+		return nil, err
+	}
+	if saved != nil {
+		*saved = CropState{Strategy: strategy, Width: size.X, Height: size.Y, Box: box}
+	}
+	return imaging.Resize(imaging.Crop(img, box), width, height, imaging.Lanczos), nil
+}
+
+// chooseCrop returns the largest box in the photo with the aspect ratio of width x height, positioned by the strategy.
+func chooseCrop(img image.Image, strategy string, width, height int) (image.Rectangle, error) {
+	bounds := img.Bounds()
+	size := bounds.Size()
+
+	// the box is the full height of the photo and slides across, or the full width and slides down
+	boxW, boxH := size.X, size.Y
+	horizontal := float64(size.X)/float64(size.Y) > float64(width)/float64(height)
+	if horizontal {
+		boxW = int(math.Round(float64(size.Y) * float64(width) / float64(height)))
+	} else {
+		boxH = int(math.Round(float64(size.X) * float64(height) / float64(width)))
+	}
+	place := func(offset int) image.Rectangle {
+		if horizontal {
+			return image.Rect(bounds.Min.X+offset, bounds.Min.Y, bounds.Min.X+offset+boxW, bounds.Max.Y)
+		}
+		return image.Rect(bounds.Min.X, bounds.Min.Y+offset, bounds.Max.X, bounds.Min.Y+offset+boxH)
+	}
+	slack := size.X - boxW + size.Y - boxH // only one of these is non-zero
+
+	if x, y, ok := parseFocalPoint(strategy); ok {
+		centre := y*float64(size.Y) - float64(boxH)/2
+		if horizontal {
+			centre = x*float64(size.X) - float64(boxW)/2
+		}
+		offset := int(math.Round(centre))
+		if offset < 0 {
+			offset = 0
+		}
+		if offset > slack {
+			offset = slack
+		}
+		return place(offset), nil
+	}
+
+	var score func(a *cropAnalysis, r image.Rectangle) float64
+	switch strategy {
+	case "entropy":
+		score = (*cropAnalysis).entropy
+	case "edges":
+		score = (*cropAnalysis).edges
+	case "thirds":
+		score = (*cropAnalysis).thirds
+	default:
+		return image.Rectangle{}, fmt.Errorf("unknown crop strategy %q", strategy)
+	}
+
+	a := analyseCrop(img)
+	scale := float64(a.w) / float64(size.X)
+
+	// try every offset at the analysis scale, and keep the first best one so the result is deterministic
+	best, bestScore := 0, math.Inf(-1)
+	steps := int(float64(slack) * scale)
+	for step := 0; step <= steps; step++ {
+		offset := 0
+		if steps > 0 {
+			offset = step * slack / steps
+		}
+		box := place(offset).Sub(bounds.Min)
+		r := image.Rect(
+			int(float64(box.Min.X)*scale),
+			int(float64(box.Min.Y)*scale),
+			int(math.Ceil(float64(box.Max.X)*scale)),
+			int(math.Ceil(float64(box.Max.Y)*scale)),
+		).Intersect(image.Rect(0, 0, a.w, a.h))
+		if s := score(a, r); s > bestScore {
+			best, bestScore = offset, s
+		}
+	}
+	return place(best), nil
+}
+
+// checkCropStrategy returns an error if the strategy isn't known.
+func checkCropStrategy(strategy string) error {
+	if _, ok := cropAnchors[strategy]; ok || strategy == "" {
+		return nil
+	}
+	if _, _, ok := parseFocalPoint(strategy); ok {
+		return nil
+	}
+	switch strategy {
+	case "fit", "entropy", "edges", "thirds":
+		return nil
+	}
+	return fmt.Errorf("unknown crop strategy %q", strategy)
+}
+
+func parseFocalPoint(s string) (x, y float64, ok bool) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	x, errX := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	y, errY := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if errX != nil || errY != nil || x < 0 || x > 1 || y < 0 || y > 1 {
+		return 0, 0, false
+	}
+	return x, y, true
+}
+
+// cropAnalysis holds the luminance and edge strength of the scaled down photo.
+type cropAnalysis struct {
+	w, h  int
+	lum   []float64 // 0 to 255
+	edge  []float64 // sobel magnitude
+	table []float64 // summed area table of edge, (w+1) x (h+1)
+}
+
+func analyseCrop(img image.Image) *cropAnalysis {
+	small := imaging.Grayscale(imaging.Fit(img, cropAnalysisSize, cropAnalysisSize, imaging.Box))
+	w, h := small.Bounds().Dx(), small.Bounds().Dy()
+	a := &cropAnalysis{w: w, h: h, lum: make([]float64, w*h), edge: make([]float64, w*h)}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			a.lum[y*w+x] = float64(small.Pix[y*small.Stride+x*4])
+		}
+	}
+	at := func(x, y int) float64 {
+		if x < 0 {
+			x = 0
+		}
+		if x >= w {
+			x = w - 1
+		}
+		if y < 0 {
+			y = 0
+		}
+		if y >= h {
+			y = h - 1
+		}
+		return a.lum[y*w+x]
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			gx := at(x+1, y-1) + 2*at(x+1, y) + at(x+1, y+1) - at(x-1, y-1) - 2*at(x-1, y) - at(x-1, y+1)
+			gy := at(x-1, y+1) + 2*at(x, y+1) + at(x+1, y+1) - at(x-1, y-1) - 2*at(x, y-1) - at(x+1, y-1)
+			a.edge[y*w+x] = math.Sqrt(gx*gx + gy*gy)
+		}
+	}
+	a.table = make([]float64, (w+1)*(h+1))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			a.table[(y+1)*(w+1)+x+1] = a.edge[y*w+x] + a.table[y*(w+1)+x+1] + a.table[(y+1)*(w+1)+x] - a.table[y*(w+1)+x]
+		}
+	}
+	return a
+}
+
+// entropy scores the box by the Shannon entropy of its luminance histogram: busy, detailed areas score higher than
+// sky or snow.
+func (a *cropAnalysis) entropy(r image.Rectangle) float64 {
+	var histogram [64]float64
+	var total float64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			histogram[int(a.lum[y*a.w+x])/4]++
+			total++
+		}
+	}
+	var e float64
+	for _, n := range histogram {
+		if n > 0 {
+			p := n / total
+			e -= p * math.Log2(p)
+		}
+	}
+	return e
+}
+
+// edges scores the box by the average edge strength inside it.
+func (a *cropAnalysis) edges(r image.Rectangle) float64 {
+	if r.Empty() {
+		return 0
+	}
+	w := a.w + 1
+	sum := a.table[r.Max.Y*w+r.Max.X] - a.table[r.Min.Y*w+r.Max.X] - a.table[r.Max.Y*w+r.Min.X] + a.table[r.Min.Y*w+r.Min.X]
+	return sum / float64(r.Dx()*r.Dy())
+}
+
+// thirds scores the box by its edge strength weighted towards the rule of thirds intersections, so the detail ends
+// up on the thirds rather than just anywhere in the frame.
+func (a *cropAnalysis) thirds(r image.Rectangle) float64 {
+	if r.Empty() {
+		return 0
+	}
+	const sigma = 0.15
+	var sum float64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		fy := (float64(y-r.Min.Y) + 0.5) / float64(r.Dy())
+		for x := r.Min.X; x < r.Max.X; x++ {
+			fx := (float64(x-r.Min.X) + 0.5) / float64(r.Dx())
+			var weight float64
+			for _, px := range []float64{1.0 / 3, 2.0 / 3} {
+				for _, py := range []float64{1.0 / 3, 2.0 / 3} {
+					d := (fx-px)*(fx-px) + (fy-py)*(fy-py)
+					if w := math.Exp(-d / (2 * sigma * sigma)); w > weight {
+						weight = w
+					}
+				}
+			}
+			sum += a.edge[y*a.w+x] * weight
+		}
+	}
+	return sum / float64(r.Dx()*r.Dy())
+}
@@ -6,13 +6,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"os"
 	"strings"
 	"text/template"
 	"time"
 
 	"github.com/dustin/go-humanize"
-	drive "google.golang.org/api/drive/v3"
 	youtube "google.golang.org/api/youtube/v3"
 )
 
@@ -22,6 +20,7 @@ var antDayDescriptionTemplate = template.Must(template.New("main").Parse(`{{ ""
 Antarctica expedition - {{ .DayAndDate }}.
 
 {{ .Long }}
+{{- .Links }}
 
 The Antarctic Peninsular
 
@@ -33,6 +32,8 @@ More info about my preparation: https://www.wildernessprime.com/expeditions/anta
 
 Music in this episode by Blue Dot Sessions: https://www.sessions.blue/
 
+{{- .Index }}
+
 `))
 
 //var ghtTitleTemplate = template.Must(template.New("main").Parse(`{{ .Title }} Great Himalaya Trail Day {{ .Key }}`))
@@ -49,6 +50,7 @@ var highlightsTemplate = template.Must(template.New("main").Parse(`{{ if .To }}T
 
 var ghtDayDescriptionTemplate = template.Must(template.New("main").Parse(`{{ "" -}}
 Great Himalaya Trail - Day {{ .Key }} - {{ .DateString }} in the {{ .Section }} section. {{ .Highlights }} {{ .Title }} 
+{{- .Links }}
 
 🔽 The Great Himalaya Trail
 
@@ -113,6 +115,15 @@ func getAntData() ([]*AntVideoData, error) {
 		item.LiveTime = AntStartTime.Add(time.Duration(i*24) * time.Hour)
 		i++
 	}
+	var total int
+	for _, item := range data {
+		if item.Type == "day" && item.Key > total {
+			total = item.Key
+		}
+	}
+	for _, item := range data {
+		item.TotalDays = total
+	}
 	return data, nil
 }
 
@@ -144,120 +155,94 @@ func getGhtData() ([]*GhtVideoData, error) {
 		item.LiveTime = GhtStartTime.Add(time.Duration(i*24) * time.Hour)
 		i++
 	}
+	var total int
+	for _, item := range data {
+		if item.Type == "day" && item.Key > total {
+			total = item.Key
+		}
+	}
+	for _, item := range data {
+		item.TotalDays = total
+	}
 	return data, nil
 }
 
-func getIndexGht(pointer int, data []*GhtVideoData, usa bool, typ string) string {
-
-	type sectionData struct {
-		name         string
-		min, max     int
-		firstVideoId string
-	}
-
-	var sectionsOrdered []*sectionData
-	sections := map[string]*sectionData{}
-	var currentSection string
-
+// getLinks returns the previous and next episode links. Episodes that haven't been uploaded yet are omitted, so
+// inserting a new video changes the links of its neighbours.
+func getLinks(prev, next string) string {
 	var sb strings.Builder
+	if prev != "" || next != "" {
+		sb.WriteString("\n")
+	}
+	if prev != "" {
+		sb.WriteString(fmt.Sprintf("\n⏪ Previous episode: %s", prev))
+	}
+	if next != "" {
+		sb.WriteString(fmt.Sprintf("\n⏩ Next episode: %s", next))
+	}
+	return sb.String()
+}
 
-	{
-		var sectionName string
-		for _, item := range data {
-			if item.Section == "" {
-				continue
-			}
-			if item.Key == pointer {
-				currentSection = item.Section
-			}
-			if item.Section != sectionName {
-				sectionName = item.Section
-				s := &sectionData{
-					name: item.Section,
-				}
-				if item.Video != nil {
-					s.firstVideoId = item.Video.Id
-				}
-				sections[item.Section] = s
-				sectionsOrdered = append(sectionsOrdered, s)
-			}
-
-			section := sections[item.Section]
-			if item.Key < section.min || section.min == 0 {
-				section.min = item.Key
-			}
-			if item.Key > section.max || section.max == 0 {
-				section.max = item.Key
-			}
+func getLinksGht(pointer int, data []*GhtVideoData) string {
+	var days []*GhtVideoData
+	for _, item := range data {
+		if item.Expedition == "ght" && item.Type == "day" && item.HasVideo {
+			days = append(days, item)
 		}
 	}
-
-	if typ == "day" {
-
-		sb.WriteString(fmt.Sprintf("\n\n🔽 %s Section\n", currentSection))
-
-		for _, item := range data {
-			if item.Section != currentSection {
-				continue
-			}
-			sb.WriteString(fmt.Sprintf("\nDay %d - ", item.Key))
-			if item.From == "" {
-				sb.WriteString(item.ZeroDayDescription())
-			} else {
-				if item.Pass != "" {
-					pass := item.Pass
-					passM := item.PassM
-					passFt := item.PassFt
-					if item.Key == 117 {
-						// special case for Mesokanto La
-						pass = item.SecondPass
-						passM = item.SecondPassM
-						passFt = item.SecondPassFt
-					}
-
-					if usa {
-						sb.WriteString(fmt.Sprintf("%s via %s %s ft", titleCase(item.To), titleCase(pass), humanize.Comma(int64(passFt))))
-					} else {
-						sb.WriteString(fmt.Sprintf("%s via %s %s m", titleCase(item.To), titleCase(pass), humanize.Comma(int64(passM))))
-					}
-				} else {
-					if item.To != "" {
-						sb.WriteString(titleCase(item.To))
-					} else {
-						sb.WriteString(titleCase(item.From))
-					}
-				}
-				if item.End != "" {
-					sb.WriteString(fmt.Sprintf(" %s", item.End))
-				}
-				if item.Video != nil {
-					sb.WriteString(fmt.Sprintf(" - https://youtu.be/%s", item.Video.Id))
-				}
-				if item.Key == pointer {
-					sb.WriteString("  ⬅️ THIS EPISODE")
-				}
-			}
+	link := func(item *GhtVideoData) string {
+		if item.Video == nil || item.Video.Id == "" {
+			return ""
 		}
+		return fmt.Sprintf("Day %d - https://youtu.be/%s", item.Key, item.Video.Id)
 	}
+	var prev, next string
+	for i, item := range days {
+		if item.Key != pointer {
+			continue
+		}
+		if i > 0 {
+			prev = link(days[i-1])
+		}
+		if i < len(days)-1 {
+			next = link(days[i+1])
+		}
+	}
+	return getLinks(prev, next)
+}
 
-	sb.WriteString("\n\n🔽 Sections\n")
-
-	for _, section := range sectionsOrdered {
-		sb.WriteString(fmt.Sprintf("\nDay %d to %d - %s Section", section.min, section.max, section.name))
-		if section.firstVideoId != "" {
-			sb.WriteString(fmt.Sprintf(" - https://youtu.be/%s", section.firstVideoId))
+func getLinksAnt(pointer int, data []*AntVideoData) string {
+	var days []*AntVideoData
+	for _, item := range data {
+		if item.Expedition == "ant" && item.Type == "day" {
+			days = append(days, item)
 		}
-		if typ == "day" && section.name == currentSection {
-			sb.WriteString("  ⬅️ THIS SECTION")
+	}
+	link := func(item *AntVideoData) string {
+		if item.Video == nil || item.Video.Id == "" {
+			return ""
 		}
+		return fmt.Sprintf("Day %d - https://youtu.be/%s", item.Key, item.Video.Id)
 	}
-	return sb.String()
+	var prev, next string
+	for i, item := range days {
+		if item.Key != pointer {
+			continue
+		}
+		if i > 0 {
+			prev = link(days[i-1])
+		}
+		if i < len(days)-1 {
+			next = link(days[i+1])
+		}
+	}
+	return getLinks(prev, next)
 }
 
 func antUpdateAllStrings(data []*AntVideoData) error {
 	for _, item := range data {
 		if item.Expedition == "ant" && item.Type == "day" {
-			if err := updateStringsAntDay(item); err != nil {
+			if err := updateStringsAntDay(item, getIndexAnt(item.Key, data), getLinksAnt(item.Key, data)); err != nil {
 				return fmt.Errorf("updating strings: %w", err)
 			}
 		}
@@ -271,10 +256,11 @@ func ghtUpdateAllStrings(data []*GhtVideoData) error {
 			continue
 		}
 		if item.Expedition == "ght" && item.Type == "day" {
-			if err := updateStringsGhtDay(item, true, getIndexGht(item.Key, data, true, "day")); err != nil {
+			links := getLinksGht(item.Key, data)
+			if err := updateStringsGhtDay(item, true, getIndexGht(item.Key, data, true, "day"), links); err != nil {
 				return fmt.Errorf("updating strings: %w", err)
 			}
-			if err := updateStringsGhtDay(item, false, getIndexGht(item.Key, data, false, "day")); err != nil {
+			if err := updateStringsGhtDay(item, false, getIndexGht(item.Key, data, false, "day"), links); err != nil {
 				return fmt.Errorf("updating strings: %w", err)
 			}
 		}
@@ -336,7 +322,7 @@ func updateStringsGhtTrailer(item *GhtVideoData, usa bool, index string) error {
 	return nil
 }
 
-func updateStringsGhtDay(item *GhtVideoData, usa bool, index string) error {
+func updateStringsGhtDay(item *GhtVideoData, usa bool, index, links string) error {
 
 	v := struct {
 		*GhtVideoData
@@ -344,12 +330,14 @@ func updateStringsGhtDay(item *GhtVideoData, usa bool, index string) error {
 		MaxLocal    string
 		AvgLocal    string
 		Index       string
+		Links       string
 		ChangeLocal string
 		Self        string
 		Transport   string
 	}{
 		GhtVideoData: item,
 		Index:        index,
+		Links:        links,
 	}
 
 	if item.Key < 31 {
@@ -427,12 +415,16 @@ func updateStringsGhtDay(item *GhtVideoData, usa bool, index string) error {
 	return nil
 }
 
-func updateStringsAntDay(item *AntVideoData) error {
+func updateStringsAntDay(item *AntVideoData, index, links string) error {
 
 	v := struct {
 		*AntVideoData
+		Index string
+		Links string
 	}{
 		AntVideoData: item,
+		Index:        index,
+		Links:        links,
 	}
 
 	v.From = titleCase(v.From)
@@ -477,15 +469,20 @@ type AntVideoData struct {
 	Title      string
 	Long       string
 	DayAndDate string
+	Crop       string // thumbnail crop strategy, overriding the layout (see cropAnchors)
+	Adjust     string // thumbnail photo adjustments, over the layout's (see parseAdjustments)
+	TotalDays  int    // number of the last day, for the thumbnail progress bar
+	Logo       bool   // draw the channel logo on the thumbnail
+	Sponsored  bool   // draw the sponsor mark on the thumbnail
 
 	LiveTime         time.Time
-	File             *drive.File
-	Thumbnail        *drive.File
+	File             *mediaFile
+	Thumbnail        *mediaFile
+	Captions         []*mediaFile
 	Video            *youtube.Video
-	PlaylistItem     *youtube.PlaylistItem
 	FullTitle        string
 	FullDescription  string
-	ThumbnailTesting os.FileInfo
+	ThumbnailTesting *mediaFile
 }
 
 type GhtVideoData struct {
@@ -518,9 +515,16 @@ type GhtVideoData struct {
 	DayAndDate         string
 	Desc               string
 	Special            bool
-	File               *drive.File
-	Thumbnail          *drive.File
-	ThumbnailTesting   os.FileInfo
+	Crop               string // thumbnail crop strategy, overriding the layout (see cropAnchors)
+	Adjust             string // thumbnail photo adjustments, over the layout's (see parseAdjustments)
+	TotalDays          int    // number of the last day, for the thumbnail progress bar
+	Badges             bool   // draw the elevation, section and progress badges on the thumbnail
+	Logo               bool   // draw the channel logo on the thumbnail
+	Sponsored          bool   // draw the sponsor mark on the thumbnail
+	File               *mediaFile
+	Thumbnail          *mediaFile
+	Captions           []*mediaFile
+	ThumbnailTesting   *mediaFile
 	Video              *youtube.Video
 	DateString         string
 	FullTitle          string
@@ -529,7 +533,7 @@ type GhtVideoData struct {
 	FullDescriptionUsa string
 	Position           int
 	LiveTime           time.Time
-	PlaylistItem       *youtube.PlaylistItem
+	SectionPlaylist    string
 	Highlights         string
 }
 
@@ -575,6 +579,26 @@ func (item AntVideoData) GetFilename() (string, error) {
 	return base64.StdEncoding.EncodeToString(metaDataBytes), nil
 }
 
+// HighPointM is the highest elevation of the day in metres: the highest pass, or the destination if that's higher.
+func (item GhtVideoData) HighPointM() int {
+	return maxInt(item.PassM, item.SecondPassM, item.ToM)
+}
+
+// HighPointFt is HighPointM in feet.
+func (item GhtVideoData) HighPointFt() int {
+	return maxInt(item.PassFt, item.SecondPassFt, item.ToFt)
+}
+
+func maxInt(values ...int) int {
+	var max int
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	return max
+}
+
 func (item GhtVideoData) ZeroDayDescription() string {
 	switch item.Rest {
 	case "ADMIN":
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -11,21 +12,37 @@ import (
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
 )
 
-func getFilesInFolder(srv *drive.Service, folderId string) ([]*drive.File, error) {
+const driveFolderMimeType = "application/vnd.google-apps.folder"
+
+// getFilesInFolder lists the files in the folder that aren't in the trash, and with recursive the files in its
+// subfolders too. Subfolders themselves aren't returned.
+func getFilesInFolder(srv *drive.Service, folderId string, recursive bool) ([]*drive.File, error) {
 	var done bool
 	var page string
 	var files []*drive.File
 
 	for !done {
-		query := fmt.Sprintf("'%s' in parents", folderId)
-		response, err := srv.Files.List().Q(query).PageSize(50).Fields("nextPageToken, files(id, name)").PageToken(page).Do()
+		query := fmt.Sprintf("'%s' in parents and trashed = false", folderId)
+		response, err := srv.Files.List().Q(query).PageSize(50).Fields("nextPageToken, files(id, name, mimeType, size, md5Checksum, modifiedTime)").PageToken(page).Do()
 		if err != nil {
 			return nil, fmt.Errorf("list files from drive: %w", err)
 		}
 		for _, file := range response.Files {
-			files = append(files, file)
+			if file.MimeType != driveFolderMimeType {
+				files = append(files, file)
+				continue
+			}
+			if !recursive {
+				continue
+			}
+			children, err := getFilesInFolder(srv, file.Id, true)
+			if err != nil {
+				return nil, fmt.Errorf("listing subfolder %q: %w", file.Name, err)
+			}
+			files = append(files, children...)
 		}
 		page = response.NextPageToken
 		if page == "" {
@@ -35,6 +52,58 @@ func getFilesInFolder(srv *drive.Service, folderId string) ([]*drive.File, error
 	return files, nil
 }
 
+// driveFolderWriter creates files in a Drive folder, or updates them in place if a file with the same name is already
+// there, so writing again doesn't leave duplicates.
+type driveFolderWriter struct {
+	srv      *drive.Service
+	folderId string
+	existing map[string]string // name to file ID
+}
+
+func newDriveFolderWriter(srv *drive.Service, folderId string) (*driveFolderWriter, error) {
+	files, err := getFilesInFolder(srv, folderId, false)
+	if err != nil {
+		return nil, fmt.Errorf("getting files in folder: %w", err)
+	}
+	w := &driveFolderWriter{srv: srv, folderId: folderId, existing: map[string]string{}}
+	for _, f := range files {
+		w.existing[f.Name] = f.Id
+	}
+	return w, nil
+}
+
+// Write uploads the contents, replacing the file's properties with the ones given.
+func (w *driveFolderWriter) Write(name, contentType string, b []byte, properties map[string]string) error {
+	file := &drive.File{MimeType: contentType, Properties: properties}
+	if id, ok := w.existing[name]; ok {
+		if _, err := w.srv.Files.Update(id, file).Media(bytes.NewReader(b), googleapi.ContentType(contentType)).Do(); err != nil {
+			return fmt.Errorf("updating %q in drive: %w", name, err)
+		}
+		return nil
+	}
+	file.Name = name
+	file.Parents = []string{w.folderId}
+	created, err := w.srv.Files.Create(file).Media(bytes.NewReader(b), googleapi.ContentType(contentType)).Fields("id").Do()
+	if err != nil {
+		return fmt.Errorf("creating %q in drive: %w", name, err)
+	}
+	w.existing[name] = created.Id
+	return nil
+}
+
+// Delete trashes the file if it's in the folder.
+func (w *driveFolderWriter) Delete(name string) error {
+	id, ok := w.existing[name]
+	if !ok {
+		return nil
+	}
+	if _, err := w.srv.Files.Update(id, &drive.File{Trashed: true}).Do(); err != nil {
+		return fmt.Errorf("trashing %q in drive: %w", name, err)
+	}
+	delete(w.existing, name)
+	return nil
+}
+
 // Retrieve a token, saves the token, then returns the generated client.
 func getDriveClient(ctx context.Context, config *oauth2.Config) (*http.Client, error) {
 	// The file token.json stores the user's access and refresh tokens, and is
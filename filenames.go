@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// filenameConvention is how an expedition's media files are named: a letter for the item type, the key padded to
+// KeyWidth digits, then anything (e.g. D012.mp4, D012_kjrsmq.jpg or D012.en.srt). With Versions, a replacement file
+// can be added next to the original with a version suffix (D012_v2.mp4), and the newest version is used.
+type filenameConvention struct {
+	Types      map[string]string   // letter to item type
+	KeyWidth   int                 // digits in the key
+	Versions   bool                // allow a _v2 style version suffix
+	Extensions map[string][]string // allowed extensions in each media folder, any if not listed
+	regex      *regexp.Regexp
+}
+
+// The version suffix comes after the key, and is followed by the extension, the caption language or another suffix.
+var versionRegex = regexp.MustCompile(`_v([0-9]+)(?:[._]|$)`)
+
+func getFilenameConvention(expedition string) (*filenameConvention, error) {
+	switch expedition {
+	case "ght":
+		return GhtFilenames, nil
+	case "ant":
+		return AntFilenames, nil
+	}
+	return nil, fmt.Errorf("unknown expedition %q, should be ght or ant", expedition)
+}
+
+// namedFile is a media file with its name parsed by the convention.
+type namedFile struct {
+	*mediaFile
+	Type     string
+	Key      int
+	Version  int    // 1 without a version suffix
+	Language string // only for captions, so each language has its own versions
+
+	SupersededBy string // name of the newer version that's used instead
+}
+
+// Parse reads the item type, key and version from the name of a file in the media folder.
+func (c *filenameConvention) Parse(file *mediaFile, folder string) (*namedFile, error) {
+	if c.regex == nil {
+		c.regex = regexp.MustCompile(fmt.Sprintf(`^([A-Z])([0-9]{%d})(.*)$`, c.KeyWidth))
+	}
+	matches := c.regex.FindStringSubmatch(file.Name)
+	if matches == nil {
+		return nil, fmt.Errorf("found file with unknown filename %q", file.Name)
+	}
+	typ, ok := c.Types[matches[1]]
+	if !ok {
+		return nil, fmt.Errorf("unknown type letter %q in filename %q", matches[1], file.Name)
+	}
+	if allowed, ok := c.Extensions[folder]; ok {
+		ext := strings.ToLower(filepath.Ext(file.Name))
+		if !containsString(allowed, ext) {
+			return nil, fmt.Errorf("filename %q should have one of the extensions %s", file.Name, strings.Join(allowed, ", "))
+		}
+	}
+	key, err := strconv.Atoi(matches[2])
+	if err != nil {
+		return nil, fmt.Errorf("parsing key number from %q: %w", file.Name, err)
+	}
+	named := &namedFile{mediaFile: file, Type: typ, Key: key, Version: 1}
+	if c.Versions {
+		if v := versionRegex.FindStringSubmatch(matches[3]); v != nil {
+			named.Version, err = strconv.Atoi(v[1])
+			if err != nil {
+				return nil, fmt.Errorf("parsing version number from %q: %w", file.Name, err)
+			}
+		}
+	}
+	if folder == CaptionMedia {
+		named.Language, _, err = parseCaptionFilename(file.Name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return named, nil
+}
+
+// Format returns the start of the filename for an item, e.g. D012.
+func (c *filenameConvention) Format(typ string, key int) (string, error) {
+	var letters []string
+	for letter, t := range c.Types {
+		if t == typ {
+			letters = append(letters, letter)
+		}
+	}
+	if len(letters) == 0 {
+		return "", fmt.Errorf("no type letter for %q", typ)
+	}
+	sort.Strings(letters)
+	return fmt.Sprintf("%s%0*d", letters[0], c.KeyWidth, key), nil
+}
+
+// List lists the media folder and parses the filenames. Where an item has more than one version of a file, only the
+// newest is returned, and the others are returned as superseded (see newestVersions).
+func (c *filenameConvention) List(media mediaSource, folder string) (files, superseded []*namedFile, err error) {
+	list, err := media.List(folder)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting files in folder: %w", err)
+	}
+	var named []*namedFile
+	for _, file := range list {
+		n, err := c.Parse(file, folder)
+		if err != nil {
+			return nil, nil, err
+		}
+		named = append(named, n)
+	}
+	files, superseded = newestVersions(named)
+	return files, superseded, nil
+}
+
+// newestVersions picks one file for each item (and caption language): the highest version, then the most recently
+// modified, then the last name in alphabetical order, so the choice doesn't depend on the listing order. The files are
+// returned in name order.
+func newestVersions(files []*namedFile) (newest, superseded []*namedFile) {
+	sorted := append([]*namedFile(nil), files...)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Version != b.Version {
+			return a.Version > b.Version
+		}
+		if !a.Modified.Equal(b.Modified) {
+			return a.Modified.After(b.Modified)
+		}
+		return a.Name > b.Name
+	})
+	chosen := map[string]*namedFile{}
+	for _, f := range sorted {
+		group := fmt.Sprintf("%s/%d/%s", f.Type, f.Key, f.Language)
+		if newer, ok := chosen[group]; ok {
+			f.SupersededBy = newer.Name
+			superseded = append(superseded, f)
+			continue
+		}
+		chosen[group] = f
+		newest = append(newest, f)
+	}
+	byName := func(files []*namedFile) {
+		sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	}
+	byName(newest)
+	byName(superseded)
+	return newest, superseded
+}
+
+// reportSuperseded prints the files that weren't used because there's a newer version.
+func reportSuperseded(folder string, superseded []*namedFile) {
+	if len(superseded) == 0 {
+		return
+	}
+	fmt.Printf("%d superseded files in %s, not used:\n", len(superseded), folder)
+	for _, f := range superseded {
+		fmt.Printf("  %s, superseded by %s\n", f.Name, f.SupersededBy)
+	}
+}
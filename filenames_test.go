@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listedSource is a media source that only lists, for testing filename conventions.
+type listedSource map[string][]*mediaFile
+
+func (s listedSource) List(folder string) ([]*mediaFile, error) {
+	return s[folder], nil
+}
+
+func (s listedSource) Open(file *mediaFile) (io.ReadCloser, error) {
+	return nil, fmt.Errorf("can't open %q", file.Name)
+}
+
+// testFiles lists files named like "D012_v2.mp4", optionally followed by "@" and a modified day (e.g. "D012.mp4@3").
+func testFiles(names string) []*mediaFile {
+	var files []*mediaFile
+	for _, name := range strings.Fields(names) {
+		file := &mediaFile{Id: name, Name: name}
+		if i := strings.Index(name, "@"); i > -1 {
+			var day int
+			fmt.Sscan(name[i+1:], &day)
+			file.Name = name[:i]
+			file.Modified = time.Date(2020, 3, day, 0, 0, 0, 0, time.UTC)
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+func namedFileNames(files []*namedFile) string {
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+func TestFilenameConventionList(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		folder     string
+		files      string
+		want       string
+		superseded string
+	}{
+		{name: "one version", folder: VideoMedia, files: "D012.mp4 D013.mp4", want: "D012.mp4 D013.mp4"},
+		{name: "version 2", folder: VideoMedia, files: "D012.mp4 D012_v2.mp4", want: "D012_v2.mp4", superseded: "D012.mp4"},
+		{name: "version 10 is newer than 2", folder: VideoMedia, files: "D012_v2.mp4 D012_v10.mp4 D012.mp4", want: "D012_v10.mp4", superseded: "D012.mp4 D012_v2.mp4"},
+		{name: "version before another suffix", folder: ThumbnailMedia, files: "D012_kjrsmq.jpg D012_v3_kjrsmq.jpg", want: "D012_v3_kjrsmq.jpg", superseded: "D012_kjrsmq.jpg"},
+		{name: "equal versions, newest modified", folder: VideoMedia, files: "D012_v2.mp4@5 D012_v2_final.mp4@3", want: "D012_v2.mp4", superseded: "D012_v2_final.mp4"},
+		{name: "equal versions and times, last name", folder: VideoMedia, files: "D012_b.mp4@3 D012_a.mp4@3", want: "D012_b.mp4", superseded: "D012_a.mp4"},
+		{name: "version beats modified", folder: VideoMedia, files: "D012.mp4@9 D012_v2.mp4@1", want: "D012_v2.mp4", superseded: "D012.mp4"},
+		{name: "types are separate", folder: VideoMedia, files: "D001.mp4 T001.mp4 D001_v2.mp4", want: "D001_v2.mp4 T001.mp4", superseded: "D001.mp4"},
+		{name: "captions per language", folder: CaptionMedia, files: "D012.en.srt D012.ne.srt D012_v2.en.vtt", want: "D012.ne.srt D012_v2.en.vtt", superseded: "D012.en.srt"},
+		{name: "caption regions", folder: CaptionMedia, files: "D012.en.srt D012.en-US.srt", want: "D012.en-US.srt D012.en.srt"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			files, superseded, err := GhtFilenames.List(listedSource{test.folder: testFiles(test.files)}, test.folder)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := namedFileNames(files); got != test.want {
+				t.Errorf("got %q, expected %q", got, test.want)
+			}
+			if got := namedFileNames(superseded); got != test.superseded {
+				t.Errorf("superseded %q, expected %q", got, test.superseded)
+			}
+		})
+	}
+}
+
+func TestFilenameConventionParse(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		folder   string
+		typ      string
+		key      int
+		version  int
+		language string
+		err      bool
+	}{
+		{name: "D012.mp4", folder: VideoMedia, typ: "day", key: 12, version: 1},
+		{name: "T001.MOV", folder: VideoMedia, typ: "trailer", key: 1, version: 1},
+		{name: "D150_v10.mp4", folder: VideoMedia, typ: "day", key: 150, version: 10},
+		{name: "D012_kjrsmq.jpeg", folder: ThumbnailMedia, typ: "day", key: 12, version: 1},
+		{name: "D012_video.mp4", folder: VideoMedia, typ: "day", key: 12, version: 1},
+		{name: "D012_v2.en-US.vtt", folder: CaptionMedia, typ: "day", key: 12, version: 2, language: "en-US"},
+		{name: "D012.jpg", folder: VideoMedia, err: true},
+		{name: "D012.mp4", folder: ThumbnailMedia, err: true},
+		{name: "D012.txt", folder: CaptionMedia, err: true},
+		{name: "D012.srt", folder: CaptionMedia, err: true},
+		{name: "X012.mp4", folder: VideoMedia, err: true},
+		{name: "D12.mp4", folder: VideoMedia, err: true},
+		{name: "day 12.mp4", folder: VideoMedia, err: true},
+	} {
+		named, err := GhtFilenames.Parse(&mediaFile{Name: test.name}, test.folder)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s in %s: expected an error", test.name, test.folder)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s in %s: %v", test.name, test.folder, err)
+			continue
+		}
+		got := []interface{}{named.Type, named.Key, named.Version, named.Language}
+		want := []interface{}{test.typ, test.key, test.version, test.language}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s in %s: got %v, expected %v", test.name, test.folder, got, want)
+		}
+	}
+}
+
+func TestFilenameConventionFormat(t *testing.T) {
+	for _, test := range []struct {
+		convention *filenameConvention
+		typ        string
+		key        int
+		want       string
+	}{
+		{convention: GhtFilenames, typ: "day", key: 12, want: "D012"},
+		{convention: GhtFilenames, typ: "day", key: 150, want: "D150"},
+		{convention: GhtFilenames, typ: "trailer", key: 1, want: "T001"},
+		{convention: AntFilenames, typ: "day", key: 4, want: "A004"},
+	} {
+		base, err := test.convention.Format(test.typ, test.key)
+		if err != nil {
+			t.Errorf("%s %d: %v", test.typ, test.key, err)
+			continue
+		}
+		if base != test.want {
+			t.Errorf("%s %d: got %q, expected %q", test.typ, test.key, base, test.want)
+		}
+		// the formatted name parses back to the same item
+		named, err := test.convention.Parse(&mediaFile{Name: base + ".mp4"}, VideoMedia)
+		if err != nil {
+			t.Errorf("parsing %q: %v", base, err)
+			continue
+		}
+		if named.Type != test.typ || named.Key != test.key {
+			t.Errorf("%q parsed as %s %d, expected %s %d", base, named.Type, named.Key, test.typ, test.key)
+		}
+	}
+	if _, err := AntFilenames.Format("trailer", 1); err == nil {
+		t.Error("expected an error for a type with no letter")
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"path"
+	"sync"
+
+	"github.com/golang/freetype"
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/math/fixed"
+)
+
+// The fonts folder is embedded in the binary, so thumbnails render from any working directory.
+//
+//go:embed fonts/*.ttf
+var embeddedFonts embed.FS
+
+// The Go fonts are built in as a last resort: they cover Latin, Greek and Cyrillic, but not Devanagari.
+var builtinFonts = map[string][]byte{
+	"go-regular": goregular.TTF,
+	"go-bold":    gobold.TTF,
+}
+
+// Fonts are parsed once, the first time a layout uses them.
+var fontCache = struct {
+	sync.Mutex
+	fonts map[string]*truetype.Font
+}{fonts: map[string]*truetype.Font{}}
+
+// getFont returns the named font: a file in the embedded fonts folder (e.g. "JosefinSans-Bold.ttf"), a built in Go
+// font, or failing those a TTF file on disk.
+func getFont(name string) (*truetype.Font, error) {
+	fontCache.Lock()
+	defer fontCache.Unlock()
+	if f, ok := fontCache.fonts[name]; ok {
+		return f, nil
+	}
+	b, ok := builtinFonts[name]
+	if !ok {
+		var err error
+		b, err = embeddedFonts.ReadFile(path.Join("fonts", path.Base(name)))
+		if err != nil {
+			b, err = ioutil.ReadFile(name)
+			if err != nil {
+				return nil, fmt.Errorf("font %q isn't embedded and can't be read: %w", name, err)
+			}
+		}
+	}
+	f, err := freetype.ParseFont(b)
+	if err != nil {
+		return nil, fmt.Errorf("parsing font %q: %w", name, err)
+	}
+	fontCache.fonts[name] = f
+	return f, nil
+}
+
+// fontChain is a font, then the fonts to fall back through for glyphs it doesn't have. In a layout file it's either
+// a single font name or a list.
+type fontChain []string
+
+func (c *fontChain) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := json.Unmarshal(b, &name); err == nil {
+		*c = fontChain{name}
+		return nil
+	}
+	var names []string
+	if err := json.Unmarshal(b, &names); err != nil {
+		return fmt.Errorf("font should be a name or a list of names: %w", err)
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("font list is empty")
+	}
+	*c = names
+	return nil
+}
+
+// loadFontChain loads each font in the chain.
+func loadFontChain(chain fontChain) ([]*truetype.Font, error) {
+	var fonts []*truetype.Font
+	for _, name := range chain {
+		f, err := getFont(name)
+		if err != nil {
+			return nil, err
+		}
+		fonts = append(fonts, f)
+	}
+	return fonts, nil
+}
+
+// fontFor returns the first font in the chain with a glyph for the rune, or nil if none have one.
+func fontFor(fonts []*truetype.Font, r rune) *truetype.Font {
+	for _, f := range fonts {
+		if f.Index(r) != 0 {
+			return f
+		}
+	}
+	return nil
+}
+
+// missingGlyphs returns the runes in the text that no font in the chain has a glyph for.
+func missingGlyphs(fonts []*truetype.Font, text string) []rune {
+	var missing []rune
+	for _, r := range text {
+		if fontFor(fonts, r) == nil && !containsRune(missing, r) {
+			missing = append(missing, r)
+		}
+	}
+	return missing
+}
+
+func containsRune(list []rune, r rune) bool {
+	for _, v := range list {
+		if v == r {
+			return true
+		}
+	}
+	return false
+}
+
+// fontRun is a part of a line drawn in one font.
+type fontRun struct {
+	Font *truetype.Font
+	Text string
+}
+
+// fontRuns splits the text into runs of runes that use the same font from the chain. Runes no font has are left in
+// the first font, which draws its missing glyph box.
+func fontRuns(fonts []*truetype.Font, text string) []fontRun {
+	var runs []fontRun
+	for _, r := range text {
+		f := fontFor(fonts, r)
+		if f == nil {
+			f = fonts[0]
+		}
+		if len(runs) > 0 && runs[len(runs)-1].Font == f {
+			runs[len(runs)-1].Text += string(r)
+			continue
+		}
+		runs = append(runs, fontRun{Font: f, Text: string(r)})
+	}
+	return runs
+}
+
+// newFallbackFace returns a face that measures each rune with the first font in the chain that has it. There's no
+// shaping, so scripts like Devanagari get their glyphs but not their conjuncts or reordered vowel signs.
+func newFallbackFace(fonts []*truetype.Font, size float64) font.Face {
+	face := &fallbackFace{fonts: fonts}
+	for _, f := range fonts {
+		face.faces = append(face.faces, truetype.NewFace(f, &truetype.Options{Size: size, DPI: 72, Hinting: font.HintingNone}))
+	}
+	return face
+}
+
+type fallbackFace struct {
+	fonts []*truetype.Font
+	faces []font.Face
+}
+
+func (f *fallbackFace) face(r rune) font.Face {
+	for i, ft := range f.fonts {
+		if ft.Index(r) != 0 {
+			return f.faces[i]
+		}
+	}
+	return f.faces[0]
+}
+
+func (f *fallbackFace) Close() error {
+	for _, face := range f.faces {
+		face.Close()
+	}
+	return nil
+}
+
+func (f *fallbackFace) Glyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	return f.face(r).Glyph(dot, r)
+}
+
+func (f *fallbackFace) GlyphBounds(r rune) (fixed.Rectangle26_6, fixed.Int26_6, bool) {
+	return f.face(r).GlyphBounds(r)
+}
+
+func (f *fallbackFace) GlyphAdvance(r rune) (fixed.Int26_6, bool) {
+	return f.face(r).GlyphAdvance(r)
+}
+
+// Kern only applies between runes in the same font, matching how the runs are drawn.
+func (f *fallbackFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	face := f.face(r0)
+	if face != f.face(r1) {
+		return 0
+	}
+	return face.Kern(r0, r1)
+}
+
+func (f *fallbackFace) Metrics() font.Metrics {
+	return f.faces[0].Metrics()
+}
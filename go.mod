@@ -1,6 +1,6 @@
 module github.com/dave/youtube
 
-go 1.12
+go 1.16
 
 require (
 	github.com/alecthomas/template v0.0.0-20190718012654-fb15b899a751
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+)
+
+// indexEntry is a single episode in a description index. The text should already be localized (e.g. feet for the
+// en_US descriptions).
+type indexEntry struct {
+	Key      int
+	Group    string
+	Text     string
+	VideoId  string
+	Linked   bool   // list the link even before the video has an ID, as the GHT descriptions always have
+	Playlist string // if set, the list of groups links to this playlist rather than the first video
+	Zero     bool   // zero days have no episode, so they're listed without a link
+}
+
+// indexGroups describes how the groups are titled: "Kanchenjunga" -> "Kanchenjunga Section", the heading of the
+// list of all groups, and how the current group is marked in that list.
+type indexGroups struct {
+	Title   func(name string) string
+	Heading string
+	Current string
+	Ranges  bool // list a one day group as "Day 5 to 5" rather than "Day 5", as the GHT descriptions always have
+}
+
+// renderIndex renders the "🔽 <group>" block listing every episode in the current group, then the "🔽 <heading>" block
+// listing every group. Consecutive entries with the same group name form one group, so a name that comes up twice
+// (e.g. crossing the Drake Passage in both directions) is listed twice. If pointer is zero (e.g. for the trailer), only
+// the list of groups is rendered.
+func renderIndex(entries []indexEntry, groups indexGroups, pointer int) string {
+
+	type groupData struct {
+		name         string
+		min, max     int
+		firstVideoId string
+		playlist     string
+		entries      []indexEntry
+	}
+
+	var all []*groupData
+	var current *groupData
+
+	for _, entry := range entries {
+		if entry.Group == "" {
+			continue
+		}
+		if len(all) == 0 || all[len(all)-1].name != entry.Group {
+			all = append(all, &groupData{name: entry.Group, firstVideoId: entry.VideoId, playlist: entry.Playlist})
+		}
+		g := all[len(all)-1]
+		g.entries = append(g.entries, entry)
+		if entry.Key < g.min || g.min == 0 {
+			g.min = entry.Key
+		}
+		if entry.Key > g.max || g.max == 0 {
+			g.max = entry.Key
+		}
+		if pointer > 0 && entry.Key == pointer {
+			current = g
+		}
+	}
+
+	var sb strings.Builder
+
+	if pointer > 0 {
+
+		var name string
+		if current != nil {
+			name = current.name
+		}
+		sb.WriteString(fmt.Sprintf("\n\n🔽 %s\n", groups.Title(name)))
+
+		if current != nil {
+			for _, entry := range current.entries {
+				sb.WriteString(fmt.Sprintf("\nDay %d - %s", entry.Key, entry.Text))
+				if entry.Zero {
+					continue
+				}
+				if entry.VideoId != "" || entry.Linked {
+					sb.WriteString(fmt.Sprintf(" - https://youtu.be/%s", entry.VideoId))
+				}
+				if entry.Key == pointer {
+					sb.WriteString("  ⬅️ THIS EPISODE")
+				}
+			}
+		}
+	}
+
+	sb.WriteString(fmt.Sprintf("\n\n🔽 %s\n", groups.Heading))
+
+	for _, g := range all {
+		if g.min == g.max && !groups.Ranges {
+			sb.WriteString(fmt.Sprintf("\nDay %d - %s", g.min, groups.Title(g.name)))
+		} else {
+			sb.WriteString(fmt.Sprintf("\nDay %d to %d - %s", g.min, g.max, groups.Title(g.name)))
+		}
+		if g.playlist != "" {
+			sb.WriteString(fmt.Sprintf(" - https://www.youtube.com/playlist?list=%s", g.playlist))
+		} else if g.firstVideoId != "" {
+			sb.WriteString(fmt.Sprintf(" - https://youtu.be/%s", g.firstVideoId))
+		}
+		if g == current {
+			sb.WriteString(fmt.Sprintf("  ⬅️ %s", groups.Current))
+		}
+	}
+	return sb.String()
+}
+
+func getIndexGht(pointer int, data []*GhtVideoData, usa bool, typ string) string {
+	var entries []indexEntry
+	for _, item := range data {
+		if item.Section == "" {
+			continue
+		}
+		entry := indexEntry{
+			Key:      item.Key,
+			Group:    item.Section,
+			Playlist: item.SectionPlaylist,
+		}
+		if item.Video != nil {
+			entry.VideoId = item.Video.Id
+			entry.Linked = true
+		}
+		if item.From == "" {
+			entry.Zero = true
+			entry.Text = item.ZeroDayDescription()
+		} else {
+			var sb strings.Builder
+			if item.Pass != "" {
+				pass := item.Pass
+				passM := item.PassM
+				passFt := item.PassFt
+				if item.Key == 117 {
+					// special case for Mesokanto La
+					pass = item.SecondPass
+					passM = item.SecondPassM
+					passFt = item.SecondPassFt
+				}
+
+				if usa {
+					sb.WriteString(fmt.Sprintf("%s via %s %s ft", titleCase(item.To), titleCase(pass), humanize.Comma(int64(passFt))))
+				} else {
+					sb.WriteString(fmt.Sprintf("%s via %s %s m", titleCase(item.To), titleCase(pass), humanize.Comma(int64(passM))))
+				}
+			} else {
+				if item.To != "" {
+					sb.WriteString(titleCase(item.To))
+				} else {
+					sb.WriteString(titleCase(item.From))
+				}
+			}
+			if item.End != "" {
+				sb.WriteString(fmt.Sprintf(" %s", item.End))
+			}
+			entry.Text = sb.String()
+		}
+		entries = append(entries, entry)
+	}
+	if typ != "day" {
+		pointer = 0
+	}
+	return renderIndex(entries, indexGroups{
+		Title:   func(name string) string { return name + " Section" },
+		Heading: "Sections",
+		Current: "THIS SECTION",
+		Ranges:  true,
+	}, pointer)
+}
+
+// getIndexAnt builds the Antarctica index, grouped by location. None of the Antarctica entries contain measurements, so
+// the same index is used for every locale.
+func getIndexAnt(pointer int, data []*AntVideoData) string {
+	var entries []indexEntry
+	var location string
+	for _, item := range data {
+		if item.Expedition != "ant" || item.Type != "day" {
+			continue
+		}
+		// unknown locations are left blank or as "?" in the sheet, so stay in the last known location
+		if item.To != "" && item.To != "?" {
+			location = item.To
+		}
+		entry := indexEntry{
+			Key:   item.Key,
+			Group: location,
+			Text:  item.Short,
+		}
+		if item.Video != nil {
+			entry.VideoId = item.Video.Id
+		}
+		entries = append(entries, entry)
+	}
+	return renderIndex(entries, indexGroups{
+		Title:   func(name string) string { return name },
+		Heading: "Locations",
+		Current: "THIS LOCATION",
+	}, pointer)
+}
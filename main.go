@@ -8,12 +8,11 @@ import (
 	"log"
 	"os"
 	"regexp"
-	"strconv"
+	"sort"
 	"strings"
 	"time"
 
 	"golang.org/x/net/context"
-	"google.golang.org/api/drive/v3"
 	"google.golang.org/api/youtube/v3"
 )
 
@@ -23,20 +22,58 @@ const SingleKey = 0
 const UpdateThumbnails = false
 const UpdateDetails = true
 const ReorderPlaylist = false
+const SyncSectionPlaylists = false
+const UpdateCaptions = false
+const WatchProcessing = true
 
 var ApiPartsInsert = []string{"snippet", "localizations", "status"}
 var ApiPartsUpdateGht = []string{"snippet", "localizations"}
-var ApiPartsUpdateAnt = []string{"snippet", "localizations", "status"}
+var ApiPartsUpdateAnt = []string{"snippet", "localizations"}
 var ApiPartsRead = []string{"snippet", "localizations", "status", "fileDetails"}
 var PlaylistItemParts = []string{"id", "contentDetails", "snippet"}
 
 const GhtPlaylist = "PLiM-TFJI81R_X4HUrRDjwSJmK-MpqC1dW"
 const AntPlaylist = "PLiM-TFJI81R-fbq9vC9vQo_PVuys01WJo"
+const ChannelId = "UCFDggPICIlCHp3iOWMYt8cg"
+
+const GhtVideoFolder = "1SPRjcEw1nPhQbj05MejHEvWteM0pRVQD"
+const AntVideoFolder = "1Ok2FOAxkaRNaXgFC0SU_Yr5Lt9U9N0Sk"
+const GhtThumbnailFolder = "1xETuf-n2mRH0REoZp-eLXLn5bzRTe3pi"
+const AntThumbnailFolder = "10eRa2tgHJzkoi65nv3NGBwMt47TWsv4z"
+
+// Set a directory with videos, thumbnails and captions subdirectories to use it instead of the Drive folders, e.g. an
+// external SSD on the server.
+const GhtMediaDir = ""
+const AntMediaDir = ""
+
+// Set to include the files in subfolders of the media folders, e.g. videos sorted into a folder per section.
+const RecurseMediaFolders = false
 
 var AntStartTime = time.Date(2020, 9, 3, 20, 0, 0, 0, time.UTC)
 var GhtStartTime = time.Date(2020, 2, 1, 21, 0, 0, 0, time.UTC)
 
-var filenameRegex = regexp.MustCompile(`^([A-Z])([0-9]{3}).*$`)
+// Media files are named with a letter for the item type and the key, e.g. D012.mp4 or A005_kjrsmq.jpg. A replacement
+// can be added next to the original as D012_v2.mp4: see filenameConvention.
+var GhtFilenames = &filenameConvention{
+	Types:    map[string]string{"D": "day", "T": "trailer"},
+	KeyWidth: 3,
+	Versions: true,
+	Extensions: map[string][]string{
+		VideoMedia:     {".mp4", ".mov", ".m4v"},
+		ThumbnailMedia: {".jpg", ".jpeg", ".png"},
+		CaptionMedia:   {".srt", ".vtt"},
+	},
+}
+var AntFilenames = &filenameConvention{
+	Types:    map[string]string{"A": "day"},
+	KeyWidth: 3,
+	Versions: true,
+	Extensions: map[string][]string{
+		VideoMedia:     {".mp4", ".mov", ".m4v"},
+		ThumbnailMedia: {".jpg", ".jpeg", ".png"},
+		CaptionMedia:   {".srt", ".vtt"},
+	},
+}
 var metaRegex = regexp.MustCompile(`\n{(.*)}$`)
 
 const thumbnailTestingImportDir = `/Users/dave/Dropbox/Antarctica/Thumbnails`
@@ -56,7 +93,9 @@ func isLocal() bool {
 
 func main() {
 	var err error
-	if isLocal() {
+	if len(os.Args) > 1 {
+		err = runCommand(context.Background(), os.Args[1], os.Args[2:])
+	} else if isLocal() {
 		//err = CreateTrailNotes()
 		//err = updatePages(context.Background())
 		err = previewThumbnails(context.Background())
@@ -75,57 +114,47 @@ func saveAntVideos(ctx context.Context) error {
 		return fmt.Errorf("can't load days: %w", err)
 	}
 
-	driveService, err := getDriveService(ctx)
+	media, err := getMediaSource(ctx, "ant")
 	if err != nil {
-		return fmt.Errorf("can't get drive service: %w", err)
+		return fmt.Errorf("getting media source: %w", err)
 	}
 
-	f := func(folder string, expected int, expedition string, action func(*AntVideoData, *drive.File)) error {
-		files, err := getFilesInFolder(driveService, folder)
+	f := func(folder string, expected int, expedition string, action func(*AntVideoData, *mediaFile)) error {
+		files, superseded, err := AntFilenames.List(media, folder)
 		if err != nil {
-			return fmt.Errorf("getting files in folder: %w", err)
+			return err
 		}
-		if len(files) != expected {
+		reportSuperseded(folder, superseded)
+		if expected >= 0 && len(files) != expected {
 			return fmt.Errorf("should be %d files in folder, but found %d", expected, len(files))
 		}
 
 		for _, f := range files {
-			matches := filenameRegex.FindStringSubmatch(f.Name)
-			if len(matches) != 3 {
-				return fmt.Errorf("found file with unknown filename %q", f.Name)
-			} else {
-				var itemType string
-				fileType := matches[1]
-				switch fileType {
-				case "A":
-					itemType = "day"
-				}
-				keyNumber, err := strconv.Atoi(matches[2])
-				if err != nil {
-					return fmt.Errorf("parsing key number from %q: %w", f.Name, err)
-				}
-				var item *AntVideoData
-				for _, itm := range data {
-					if itm.Expedition == expedition && itm.Type == itemType && itm.Key == keyNumber {
-						item = itm
-						break
-					}
+			var item *AntVideoData
+			for _, itm := range data {
+				if itm.Expedition == expedition && itm.Type == f.Type && itm.Key == f.Key {
+					item = itm
+					break
 				}
-				if item == nil {
-					return fmt.Errorf("no item for type %s and key %d for file %q", itemType, keyNumber, f.Name)
-				}
-				action(item, f)
 			}
+			if item == nil {
+				return fmt.Errorf("no item for type %s and key %d for file %q", f.Type, f.Key, f.Name)
+			}
+			action(item, f.mediaFile)
 		}
 		return nil
 	}
 	// Video files:
-	if err := f("1Ok2FOAxkaRNaXgFC0SU_Yr5Lt9U9N0Sk", 28, "ant", func(item *AntVideoData, file *drive.File) { item.File = file }); err != nil {
-		return fmt.Errorf("getting video files from drive: %w", err)
+	if err := f(VideoMedia, 28, "ant", func(item *AntVideoData, file *mediaFile) { item.File = file }); err != nil {
+		return fmt.Errorf("getting video files: %w", err)
 	}
 	// Thumbnails:
-	if err := f("10eRa2tgHJzkoi65nv3NGBwMt47TWsv4z", 27, "ant", func(item *AntVideoData, file *drive.File) { item.Thumbnail = file }); err != nil {
-		return fmt.Errorf("getting thumbnail files from drive: %w", err)
+	if err := f(ThumbnailMedia, 27, "ant", func(item *AntVideoData, file *mediaFile) { item.Thumbnail = file }); err != nil {
+		return fmt.Errorf("getting thumbnail files: %w", err)
+	}
+	// Captions (any number of languages per video, none if there's no caption folder):
+	if err := f(CaptionMedia, -1, "ant", func(item *AntVideoData, file *mediaFile) { item.Captions = append(item.Captions, file) }); err != nil {
+		return fmt.Errorf("getting caption files: %w", err)
 	}
 
 	youtubeService, err := getYoutubeService(ctx)
@@ -133,7 +162,12 @@ func saveAntVideos(ctx context.Context) error {
 		return fmt.Errorf("getting youtube service: %w", err)
 	}
 
-	if err := getAntVideos(youtubeService, data); err != nil {
+	state, err := loadState()
+	if err != nil {
+		return fmt.Errorf("loading state: %w", err)
+	}
+
+	if err := getAntVideos(youtubeService, state, data); err != nil {
 		return fmt.Errorf("getting videos: %w", err)
 	}
 
@@ -141,163 +175,219 @@ func saveAntVideos(ctx context.Context) error {
 		return fmt.Errorf("updating all strings: %w", err)
 	}
 
-	dataByVideoId := map[string]*AntVideoData{}
 	for _, item := range data {
-		if item.Video != nil {
-			dataByVideoId[item.Video.Id] = item
+		if item.Video == nil {
+			// create new video
+			item.Video = &youtube.Video{}
 		}
+
+		// set the correct PublishAt date, but only for new videos: after that the status command manages it
+		if item.Video.Id == "" && item.Video.Status == nil {
+			item.Video.Status = newVideoStatus(item.Type, item.LiveTime)
+		}
+
+		// add basic data
+		setAntSnippet(item)
 	}
 
-	if ReorderPlaylist {
-		playlistItems, err := getPlaylist(youtubeService, AntPlaylist)
+	var layout *thumbnailLayout
+	var renditionWriter *driveFolderWriter
+	if UpdateThumbnails {
+		if err := checkAntThumbnails(data); err != nil {
+			return fmt.Errorf("checking thumbnails: %w", err)
+		}
+		layout, err = loadThumbnailLayout(AntThumbnailLayout)
+		if err != nil {
+			return err
+		}
+		renditionWriter, err = getRenditionWriter(ctx, "ant")
 		if err != nil {
-			return fmt.Errorf("getting playlist items: %w", err)
+			return err
 		}
+	}
 
-		for _, playlistItem := range playlistItems {
-			item := dataByVideoId[playlistItem.ContentDetails.VideoId]
-			item.PlaylistItem = playlistItem
-			playlistItem.Snippet.Position = int64(item.Key)
+	// Phase 1: insert the new videos and record their IDs.
+	inserted := map[*AntVideoData]bool{}
+	for _, day := range data {
+		if day.Video == nil || day.Video.Id != "" {
+			continue
 		}
-		for _, item := range data {
-			if item.PlaylistItem == nil {
-				continue
+		if !isSelected(day.Type, day.Key) {
+			continue
+		}
+
+		// insert video
+
+		if InsertVideos {
+
+			fmt.Printf("Inserting video: %q\n", day.Video.Snippet.Title)
+			call := youtubeService.Videos.Insert(ApiPartsInsert, day.Video)
+
+			fmt.Println("Downloading video", day.File.Name)
+			download, err := media.Open(day.File)
+			if err != nil {
+				return fmt.Errorf("opening video: %w", err)
 			}
-			fmt.Printf("Updating playlist item for day %d\n", item.Key)
-			_, err := youtubeService.PlaylistItems.Update(PlaylistItemParts, item.PlaylistItem).Do()
+			insertCall := call.Media(download)
+
+			filename, err := day.GetFilename()
 			if err != nil {
-				return fmt.Errorf("updating playlist item: %w", err)
+				return fmt.Errorf("generating meta data filename: %w", err)
 			}
-		}
-	}
+			insertCall.Header().Add("Slug", filename)
+			//insertCall.Header()["Slug"] = []string{filename}
 
-	for _, item := range data {
-		if item.Video == nil {
-			// create new video
-			item.Video = &youtube.Video{}
-		}
+			video, err := insertCall.Do()
+			if err != nil {
+				download.Close()
+				return fmt.Errorf("inserting video: %w", err)
+			}
+			download.Close()
 
-		// set the correct PublishAt date, but only for new videos (don't modify this on update)
-		//if item.Video.Id == "" && item.Video.Status == nil {
-		// changing this after setting it breaks the "premiere" feature?
-		// TODO: TEST THIS
-		if item.Video.Status == nil {
-			item.Video.Status = &youtube.VideoStatus{}
-		}
-		if item.Type == "day" {
-			item.Video.Status.PrivacyStatus = "private"
-			item.Video.Status.PublishAt = strings.TrimSuffix(item.LiveTime.Format(time.RFC3339), "Z") + ".0Z"
-		} else {
-			item.Video.Status.PrivacyStatus = "private"
+			day.Video = video
+			inserted[day] = true
+			state.Videos[filename] = video.Id
+			if err := state.Save(); err != nil {
+				return fmt.Errorf("saving state: %w", err)
+			}
 		}
-		//}
+	}
 
-		// add basic data
-		if item.Video.Snippet == nil {
-			item.Video.Snippet = &youtube.VideoSnippet{}
+	// Phase 2: now the new videos have IDs, re-render the strings so the neighbouring episodes link to them. This
+	// includes the videos just inserted, which were rendered before their neighbours had IDs.
+	relinked := map[*AntVideoData]bool{}
+	if len(inserted) > 0 {
+		if err := antUpdateAllStrings(data); err != nil {
+			return fmt.Errorf("updating all strings: %w", err)
+		}
+		for _, item := range data {
+			if item.Video == nil || item.Video.Id == "" {
+				continue
+			}
+			before := item.Video.Snippet.Description
+			setAntSnippet(item)
+			if item.Video.Snippet.Description != before {
+				relinked[item] = true
+			}
 		}
-		item.Video.Snippet.CategoryId = "19"
-		item.Video.Snippet.ChannelId = "UCFDggPICIlCHp3iOWMYt8cg"
-		item.Video.Snippet.DefaultAudioLanguage = "en"
-		item.Video.Snippet.DefaultLanguage = "en"
-		item.Video.Snippet.LiveBroadcastContent = "none"
-		item.Video.Snippet.Description = item.FullDescription + "\n{" + item.MustGetFilename() + "}"
-		item.Video.Snippet.Title = item.FullTitle
 	}
 
 	for _, day := range data {
-		if day.Video == nil {
+		if day.Video == nil || day.Video.Id == "" {
 			continue
 		}
-		if day.Video.Id == "" {
 
-			if SingleKey > 0 && SingleKey != day.Key {
-				continue
-			}
-			if SingleType != "" && SingleType != day.Type {
-				continue
-			}
+		// update video
 
-			// insert video
-
-			if InsertVideos {
+		if !isSelected(day.Type, day.Key) && !relinked[day] {
+			continue
+		}
 
-				fmt.Printf("Inserting video: %q\n", day.Video.Snippet.Title)
-				call := youtubeService.Videos.Insert(ApiPartsInsert, day.Video)
+		// clear FileDetails because it's not updatable
+		day.Video.FileDetails = nil
 
-				//filename, err := day.GetFilename()
-				//if err != nil {
-				//	return fmt.Errorf("generating meta data filename: %w", err)
-				//}
-				//call.Header().Add("Slug", filename)
+		// clear Status because we dont want to update it
+		day.Video.Status = nil
 
-				fmt.Println("Downloading video", day.File.Id)
-				download, err := driveService.Files.Get(day.File.Id).Download()
-				if err != nil {
-					return fmt.Errorf("downloading drive file: %w", err)
-				}
-				insertCall := call.Media(download.Body)
+		if UpdateDetails || relinked[day] {
+			fmt.Printf("Updating video: %q\n", day.Video.Snippet.Title)
+			_, err := youtubeService.Videos.Update(ApiPartsUpdateAnt, day.Video).Do()
+			if err != nil {
+				return fmt.Errorf("updating video: %w", err)
+			}
+		}
 
-				filename, err := day.GetFilename()
-				if err != nil {
-					return fmt.Errorf("generating meta data filename: %w", err)
+		if UpdateThumbnails && isSelected(day.Type, day.Key) {
+			fmt.Println("Downloading thumbnail", day.Thumbnail.Name)
+			download, err := media.Open(day.Thumbnail)
+			if err != nil {
+				return fmt.Errorf("opening thumbnail: %w", err)
+			}
+			photo, err := decodePhoto(download)
+			download.Close()
+			if err != nil {
+				return fmt.Errorf("decoding thumbnail: %w", err)
+			}
+			filename := day.MustGetFilename()
+			crop := state.Crops[filename]
+			img, err := transformAntImage(layout, day, photo, &crop)
+			if err != nil {
+				return fmt.Errorf("transforming thumbnail: %w", err)
+			}
+			if crop != state.Crops[filename] {
+				state.Crops[filename] = crop
+				if err := state.Save(); err != nil {
+					return fmt.Errorf("saving state: %w", err)
 				}
-				insertCall.Header().Add("Slug", filename)
-				//insertCall.Header()["Slug"] = []string{filename}
-
-				if _, err := insertCall.Do(); err != nil {
-					download.Body.Close()
-					return fmt.Errorf("inserting video: %w", err)
+			}
+			b, contentType, err := encodeThumbnail(img)
+			if err != nil {
+				return fmt.Errorf("encoding thumbnail: %w", err)
+			}
+			uploaded, err := setThumbnail(youtubeService, state, day.Video.Id, b, contentType)
+			if err != nil {
+				return fmt.Errorf("setting thumbnail: %w", err)
+			}
+			if uploaded && renditionWriter != nil {
+				source := &renditionSource{Key: day.Key, Type: day.Type, Crop: day.Crop, Adjust: day.Adjust, Filename: filename, Item: day, Photo: day.Thumbnail.Name, PhotoFile: day.Thumbnail}
+				if err := saveUploadedRenditions(renditionWriter, state, layout, AntFilenames, source, photo, b, contentType); err != nil {
+					return fmt.Errorf("saving renditions to drive: %w", err)
 				}
-				download.Body.Close()
-
 			}
+		}
+	}
 
-		} else {
-			// update video
-
-			if SingleKey > 0 && SingleKey != day.Key {
+	if UpdateCaptions {
+		for _, item := range data {
+			if item.Video == nil || item.Video.Id == "" || len(item.Captions) == 0 || !isSelected(item.Type, item.Key) {
 				continue
 			}
-			if SingleType != "" && SingleType != day.Type {
-				continue
+			if err := syncCaptions(youtubeService, media, state, item.Video.Id, item.Captions); err != nil {
+				return fmt.Errorf("syncing captions: %w", err)
 			}
+		}
+	}
 
-			// clear FileDetails because it's not updatable
-			day.Video.FileDetails = nil
-
-			// clear Status because we dont want to update it
-			//day.Video.Status = nil
-
-			if UpdateDetails {
-				fmt.Printf("Updating video: %q\n", day.Video.Snippet.Title)
-				_, err := youtubeService.Videos.Update(ApiPartsUpdateAnt, day.Video).Do()
-				if err != nil {
-					return fmt.Errorf("updating video: %w", err)
-				}
+	if ReorderPlaylist {
+		var videoIds []string
+		for _, item := range data {
+			if item.Type == "day" && item.Video != nil && item.Video.Id != "" {
+				videoIds = append(videoIds, item.Video.Id)
 			}
+		}
+		fmt.Println("Syncing playlist")
+		if err := syncPlaylist(youtubeService, AntPlaylist, videoIds); err != nil {
+			return fmt.Errorf("syncing playlist: %w", err)
+		}
+	}
 
-			if UpdateThumbnails {
-				fmt.Println("Downloading thumbnail", day.Thumbnail.Id)
-				download, err := driveService.Files.Get(day.Thumbnail.Id).Download()
-				if err != nil {
-					return fmt.Errorf("downloading drive file: %w", err)
-				}
-				f, err := transformAntImage(day, download.Body, false)
-				if err != nil {
-					download.Body.Close()
-					return fmt.Errorf("transforming thumbnail: %w", err)
-				}
-				download.Body.Close()
-				if _, err := youtubeService.Thumbnails.Set(day.Video.Id).Media(f).Do(); err != nil {
-					return fmt.Errorf("setting thumbnail: %w", err)
-				}
+	if WatchProcessing && len(inserted) > 0 {
+		var videoIds []string
+		for _, item := range data {
+			if inserted[item] {
+				videoIds = append(videoIds, item.Video.Id)
 			}
 		}
+		if err := watchProcessing(youtubeService, videoIds, ProcessingTimeout); err != nil {
+			return fmt.Errorf("watching processing: %w", err)
+		}
 	}
 	return nil
 }
 
+func setAntSnippet(item *AntVideoData) {
+	if item.Video.Snippet == nil {
+		item.Video.Snippet = &youtube.VideoSnippet{}
+	}
+	item.Video.Snippet.CategoryId = "19"
+	item.Video.Snippet.ChannelId = ChannelId
+	item.Video.Snippet.DefaultAudioLanguage = "en"
+	item.Video.Snippet.DefaultLanguage = "en"
+	item.Video.Snippet.LiveBroadcastContent = "none"
+	item.Video.Snippet.Description = item.FullDescription + "\n{" + item.MustGetFilename() + "}"
+	item.Video.Snippet.Title = item.FullTitle
+}
+
 func saveGhtVideos(ctx context.Context) error {
 
 	data, err := getGhtData()
@@ -305,59 +395,47 @@ func saveGhtVideos(ctx context.Context) error {
 		return fmt.Errorf("can't load days: %w", err)
 	}
 
-	driveService, err := getDriveService(ctx)
+	media, err := getMediaSource(ctx, "ght")
 	if err != nil {
-		return fmt.Errorf("can't get drive service: %w", err)
+		return fmt.Errorf("getting media source: %w", err)
 	}
 
-	f := func(folder string, expected int, expedition string, action func(*GhtVideoData, *drive.File)) error {
-		files, err := getFilesInFolder(driveService, folder)
+	f := func(folder string, expected int, expedition string, action func(*GhtVideoData, *mediaFile)) error {
+		files, superseded, err := GhtFilenames.List(media, folder)
 		if err != nil {
-			return fmt.Errorf("getting files in folder: %w", err)
+			return err
 		}
-		if len(files) != expected {
+		reportSuperseded(folder, superseded)
+		if expected >= 0 && len(files) != expected {
 			return fmt.Errorf("should be %d files in folder, but found %d", expected, len(files))
 		}
 
 		for _, f := range files {
-			matches := filenameRegex.FindStringSubmatch(f.Name)
-			if len(matches) != 3 {
-				return fmt.Errorf("found file with unknown filename %q", f.Name)
-			} else {
-				var itemType string
-				fileType := matches[1]
-				switch fileType {
-				case "D":
-					itemType = "day"
-				case "T":
-					itemType = "trailer"
-				}
-				keyNumber, err := strconv.Atoi(matches[2])
-				if err != nil {
-					return fmt.Errorf("parsing key number from %q: %w", f.Name, err)
-				}
-				var item *GhtVideoData
-				for _, itm := range data {
-					if itm.Expedition == expedition && itm.Type == itemType && itm.Key == keyNumber {
-						item = itm
-						break
-					}
+			var item *GhtVideoData
+			for _, itm := range data {
+				if itm.Expedition == expedition && itm.Type == f.Type && itm.Key == f.Key {
+					item = itm
+					break
 				}
-				if item == nil {
-					return fmt.Errorf("no item for type %s and key %d for file %q", itemType, keyNumber, f.Name)
-				}
-				action(item, f)
 			}
+			if item == nil {
+				return fmt.Errorf("no item for type %s and key %d for file %q", f.Type, f.Key, f.Name)
+			}
+			action(item, f.mediaFile)
 		}
 		return nil
 	}
 	// Video files:
-	if err := f("1SPRjcEw1nPhQbj05MejHEvWteM0pRVQD", 126, "ght", func(item *GhtVideoData, file *drive.File) { item.File = file }); err != nil {
-		return fmt.Errorf("getting video files from drive: %w", err)
+	if err := f(VideoMedia, 126, "ght", func(item *GhtVideoData, file *mediaFile) { item.File = file }); err != nil {
+		return fmt.Errorf("getting video files: %w", err)
 	}
 	// Thumbnails:
-	if err := f("1xETuf-n2mRH0REoZp-eLXLn5bzRTe3pi", 126, "ght", func(item *GhtVideoData, file *drive.File) { item.Thumbnail = file }); err != nil {
-		return fmt.Errorf("getting thumbnail files from drive: %w", err)
+	if err := f(ThumbnailMedia, 126, "ght", func(item *GhtVideoData, file *mediaFile) { item.Thumbnail = file }); err != nil {
+		return fmt.Errorf("getting thumbnail files: %w", err)
+	}
+	// Captions (any number of languages per video, none if there's no caption folder):
+	if err := f(CaptionMedia, -1, "ght", func(item *GhtVideoData, file *mediaFile) { item.Captions = append(item.Captions, file) }); err != nil {
+		return fmt.Errorf("getting caption files: %w", err)
 	}
 
 	youtubeService, err := getYoutubeService(ctx)
@@ -365,42 +443,26 @@ func saveGhtVideos(ctx context.Context) error {
 		return fmt.Errorf("getting youtube service: %w", err)
 	}
 
-	playlistItems, err := getPlaylist(youtubeService, GhtPlaylist)
+	state, err := loadState()
 	if err != nil {
-		return fmt.Errorf("getting playlist items: %w", err)
+		return fmt.Errorf("loading state: %w", err)
 	}
 
-	if err := getGhtVideos(youtubeService, data); err != nil {
+	if err := getGhtVideos(youtubeService, state, data); err != nil {
 		return fmt.Errorf("getting videos: %w", err)
 	}
 
-	if err := ghtUpdateAllStrings(data); err != nil {
-		return fmt.Errorf("updating all strings: %w", err)
+	if SyncSectionPlaylists {
+		if err := ensureGhtSectionPlaylists(youtubeService, state, data); err != nil {
+			return fmt.Errorf("creating section playlists: %w", err)
+		}
 	}
-
-	dataByVideoId := map[string]*GhtVideoData{}
 	for _, item := range data {
-		if item.Video != nil {
-			dataByVideoId[item.Video.Id] = item
-		}
+		item.SectionPlaylist = state.Playlists[item.Section]
 	}
 
-	if ReorderPlaylist {
-		for _, playlistItem := range playlistItems {
-			item := dataByVideoId[playlistItem.ContentDetails.VideoId]
-			item.PlaylistItem = playlistItem
-			playlistItem.Snippet.Position = int64(item.Position)
-		}
-		for _, item := range data {
-			if item.PlaylistItem == nil {
-				continue
-			}
-			fmt.Printf("Updating playlist item for day %d\n", item.Key)
-			_, err := youtubeService.PlaylistItems.Update(PlaylistItemParts, item.PlaylistItem).Do()
-			if err != nil {
-				return fmt.Errorf("updating playlist item: %w", err)
-			}
-		}
+	if err := ghtUpdateAllStrings(data); err != nil {
+		return fmt.Errorf("updating all strings: %w", err)
 	}
 
 	for _, item := range data {
@@ -413,126 +475,246 @@ func saveGhtVideos(ctx context.Context) error {
 			item.Video = &youtube.Video{}
 		}
 
-		// set the correct PublishAt date, but only for new videos (don't modify this on update)
+		// set the correct PublishAt date, but only for new videos: after that the status command manages it
 		if item.Video.Id == "" && item.Video.Status == nil {
-			// changing this after setting it breaks the "premiere" feature?
-			// TODO: TEST THIS
-			item.Video.Status = &youtube.VideoStatus{}
-			if item.Type == "day" {
-				item.Video.Status.PrivacyStatus = "private"
-				item.Video.Status.PublishAt = strings.TrimSuffix(item.LiveTime.Format(time.RFC3339), "Z") + ".0Z"
-			} else {
-				item.Video.Status.PrivacyStatus = "private"
-			}
+			item.Video.Status = newVideoStatus(item.Type, item.LiveTime)
 		}
 
 		// add basic data
-		if item.Video.Snippet == nil {
-			item.Video.Snippet = &youtube.VideoSnippet{}
-		}
-		item.Video.Snippet.CategoryId = "19"
-		item.Video.Snippet.ChannelId = "UCFDggPICIlCHp3iOWMYt8cg"
-		item.Video.Snippet.DefaultAudioLanguage = "en"
-		item.Video.Snippet.DefaultLanguage = "en"
-		item.Video.Snippet.LiveBroadcastContent = "none"
-		item.Video.Snippet.Description = item.FullDescription + "\n{" + item.MustGetFilename() + "}"
-		item.Video.Snippet.Title = item.FullTitle
+		setGhtSnippet(item)
+	}
 
-		// add the special USA localized title and description
-		if item.Video.Localizations == nil {
-			item.Video.Localizations = map[string]youtube.VideoLocalization{}
+	var layout *thumbnailLayout
+	var renditionWriter *driveFolderWriter
+	if UpdateThumbnails {
+		if err := checkGhtThumbnails(data); err != nil {
+			return fmt.Errorf("checking thumbnails: %w", err)
 		}
-		item.Video.Localizations["en_US"] = youtube.VideoLocalization{
-			Title:       item.FullTitleUsa,
-			Description: item.FullDescriptionUsa,
+		layout, err = loadThumbnailLayout(GhtThumbnailLayout)
+		if err != nil {
+			return err
+		}
+		renditionWriter, err = getRenditionWriter(ctx, "ght")
+		if err != nil {
+			return err
 		}
-
 	}
 
+	// Phase 1: insert the new videos and record their IDs.
+	inserted := map[*GhtVideoData]bool{}
 	for _, day := range data {
-		if day.Video == nil {
+		if day.Video == nil || day.Video.Id != "" {
+			continue
+		}
+		if !isSelected(day.Type, day.Key) {
 			continue
 		}
-		if day.Video.Id == "" {
-
-			if SingleKey > 0 && SingleKey != day.Key {
-				continue
-			}
-			if SingleType != "" && SingleType != day.Type {
-				continue
-			}
 
-			// insert video
+		// insert video
 
-			if InsertVideos {
-				fmt.Printf("Inserting video: %q\n", day.Video.Snippet.Title)
-				call := youtubeService.Videos.Insert(ApiPartsInsert, day.Video)
+		if InsertVideos {
+			fmt.Printf("Inserting video: %q\n", day.Video.Snippet.Title)
+			call := youtubeService.Videos.Insert(ApiPartsInsert, day.Video)
 
-				fmt.Println("Downloading video", day.File.Id)
-				download, err := driveService.Files.Get(day.File.Id).Download()
-				if err != nil {
-					return fmt.Errorf("downloading drive file: %w", err)
-				}
-				insertCall := call.Media(download.Body)
+			fmt.Println("Downloading video", day.File.Name)
+			download, err := media.Open(day.File)
+			if err != nil {
+				return fmt.Errorf("opening video: %w", err)
+			}
+			insertCall := call.Media(download)
 
-				filename, err := day.GetFilename()
-				if err != nil {
-					return fmt.Errorf("generating meta data filename: %w", err)
-				}
-				insertCall.Header().Add("Slug", filename)
+			filename, err := day.GetFilename()
+			if err != nil {
+				return fmt.Errorf("generating meta data filename: %w", err)
+			}
+			insertCall.Header().Add("Slug", filename)
 
-				if _, err := insertCall.Do(); err != nil {
-					download.Body.Close()
-					return fmt.Errorf("inserting video: %w", err)
-				}
-				download.Body.Close()
+			video, err := insertCall.Do()
+			if err != nil {
+				download.Close()
+				return fmt.Errorf("inserting video: %w", err)
 			}
+			download.Close()
 
-		} else {
-			// update video
+			day.Video = video
+			inserted[day] = true
+			state.Videos[filename] = video.Id
+			if err := state.Save(); err != nil {
+				return fmt.Errorf("saving state: %w", err)
+			}
+		}
+	}
 
-			if SingleKey > 0 && SingleKey != day.Key {
+	// Phase 2: now the new videos have IDs, re-render the strings so the neighbouring episodes (and the section
+	// index) link to them. This includes the videos just inserted, which were rendered before they or their
+	// neighbours had IDs.
+	relinked := map[*GhtVideoData]bool{}
+	if len(inserted) > 0 {
+		if err := ghtUpdateAllStrings(data); err != nil {
+			return fmt.Errorf("updating all strings: %w", err)
+		}
+		for _, item := range data {
+			if item.Video == nil || item.Video.Id == "" {
 				continue
 			}
-			if SingleType != "" && SingleType != day.Type {
-				continue
+			before := item.Video.Snippet.Description
+			beforeUsa := item.Video.Localizations["en_US"].Description
+			setGhtSnippet(item)
+			if item.Video.Snippet.Description != before || item.Video.Localizations["en_US"].Description != beforeUsa {
+				relinked[item] = true
 			}
+		}
+	}
 
-			// clear FileDetails because it's not updatable
-			day.Video.FileDetails = nil
+	for _, day := range data {
+		if day.Video == nil || day.Video.Id == "" {
+			continue
+		}
 
-			// clear Status because we dont want to update it
-			day.Video.Status = nil
+		// update video
 
-			if UpdateDetails {
-				fmt.Printf("Updating video: %q\n", day.Video.Snippet.Title)
-				_, err := youtubeService.Videos.Update(ApiPartsUpdateGht, day.Video).Do()
-				if err != nil {
-					return fmt.Errorf("updating video: %w", err)
-				}
+		if !isSelected(day.Type, day.Key) && !relinked[day] {
+			continue
+		}
+
+		// clear FileDetails because it's not updatable
+		day.Video.FileDetails = nil
+
+		// clear Status because we dont want to update it
+		day.Video.Status = nil
+
+		if UpdateDetails || relinked[day] {
+			fmt.Printf("Updating video: %q\n", day.Video.Snippet.Title)
+			_, err := youtubeService.Videos.Update(ApiPartsUpdateGht, day.Video).Do()
+			if err != nil {
+				return fmt.Errorf("updating video: %w", err)
 			}
+		}
 
-			if UpdateThumbnails {
-				fmt.Println("Downloading thumbnail", day.Thumbnail.Id)
-				download, err := driveService.Files.Get(day.Thumbnail.Id).Download()
-				if err != nil {
-					return fmt.Errorf("downloading drive file: %w", err)
-				}
-				f, err := transformGhtImage(day, download.Body, false)
-				if err != nil {
-					download.Body.Close()
-					return fmt.Errorf("transforming thumbnail: %w", err)
+		if UpdateThumbnails && isSelected(day.Type, day.Key) {
+			fmt.Println("Downloading thumbnail", day.Thumbnail.Name)
+			download, err := media.Open(day.Thumbnail)
+			if err != nil {
+				return fmt.Errorf("opening thumbnail: %w", err)
+			}
+			photo, err := decodePhoto(download)
+			download.Close()
+			if err != nil {
+				return fmt.Errorf("decoding thumbnail: %w", err)
+			}
+			filename := day.MustGetFilename()
+			crop := state.Crops[filename]
+			img, err := transformGhtImage(layout, day, photo, &crop)
+			if err != nil {
+				return fmt.Errorf("transforming thumbnail: %w", err)
+			}
+			if crop != state.Crops[filename] {
+				state.Crops[filename] = crop
+				if err := state.Save(); err != nil {
+					return fmt.Errorf("saving state: %w", err)
 				}
-				download.Body.Close()
-				if _, err := youtubeService.Thumbnails.Set(day.Video.Id).Media(f).Do(); err != nil {
-					return fmt.Errorf("setting thumbnail: %w", err)
+			}
+			b, contentType, err := encodeThumbnail(img)
+			if err != nil {
+				return fmt.Errorf("encoding thumbnail: %w", err)
+			}
+			uploaded, err := setThumbnail(youtubeService, state, day.Video.Id, b, contentType)
+			if err != nil {
+				return fmt.Errorf("setting thumbnail: %w", err)
+			}
+			if uploaded && renditionWriter != nil {
+				source := &renditionSource{Key: day.Key, Type: day.Type, Crop: day.Crop, Adjust: day.Adjust, Filename: filename, Item: day, Photo: day.Thumbnail.Name, PhotoFile: day.Thumbnail}
+				if err := saveUploadedRenditions(renditionWriter, state, layout, GhtFilenames, source, photo, b, contentType); err != nil {
+					return fmt.Errorf("saving renditions to drive: %w", err)
 				}
 			}
 		}
 	}
+
+	if UpdateCaptions {
+		for _, item := range data {
+			if item.Video == nil || item.Video.Id == "" || len(item.Captions) == 0 || !isSelected(item.Type, item.Key) {
+				continue
+			}
+			if err := syncCaptions(youtubeService, media, state, item.Video.Id, item.Captions); err != nil {
+				return fmt.Errorf("syncing captions: %w", err)
+			}
+		}
+	}
+
+	if ReorderPlaylist {
+		// the whole playlist in Position order, trailer included (it shares position 0 with the first day, and comes
+		// before it in the data)
+		var ordered []*GhtVideoData
+		for _, item := range data {
+			if item.Video != nil && item.Video.Id != "" {
+				ordered = append(ordered, item)
+			}
+		}
+		sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Position < ordered[j].Position })
+		var videoIds []string
+		for _, item := range ordered {
+			videoIds = append(videoIds, item.Video.Id)
+		}
+		fmt.Println("Syncing playlist")
+		if err := syncPlaylist(youtubeService, GhtPlaylist, videoIds); err != nil {
+			return fmt.Errorf("syncing playlist: %w", err)
+		}
+	}
+
+	if SyncSectionPlaylists {
+		if err := syncGhtSectionPlaylists(youtubeService, state, data); err != nil {
+			return fmt.Errorf("syncing section playlists: %w", err)
+		}
+	}
+
+	if WatchProcessing && len(inserted) > 0 {
+		var videoIds []string
+		for _, item := range data {
+			if inserted[item] {
+				videoIds = append(videoIds, item.Video.Id)
+			}
+		}
+		if err := watchProcessing(youtubeService, videoIds, ProcessingTimeout); err != nil {
+			return fmt.Errorf("watching processing: %w", err)
+		}
+	}
 	return nil
 }
 
+func setGhtSnippet(item *GhtVideoData) {
+	if item.Video.Snippet == nil {
+		item.Video.Snippet = &youtube.VideoSnippet{}
+	}
+	item.Video.Snippet.CategoryId = "19"
+	item.Video.Snippet.ChannelId = ChannelId
+	item.Video.Snippet.DefaultAudioLanguage = "en"
+	item.Video.Snippet.DefaultLanguage = "en"
+	item.Video.Snippet.LiveBroadcastContent = "none"
+	item.Video.Snippet.Description = item.FullDescription + "\n{" + item.MustGetFilename() + "}"
+	item.Video.Snippet.Title = item.FullTitle
+
+	// add the special USA localized title and description
+	if item.Video.Localizations == nil {
+		item.Video.Localizations = map[string]youtube.VideoLocalization{}
+	}
+	item.Video.Localizations["en_US"] = youtube.VideoLocalization{
+		Title:       item.FullTitleUsa,
+		Description: item.FullDescriptionUsa,
+	}
+}
+
+// isSelected reports whether the SingleType / SingleKey filters allow an item to be inserted or updated.
+func isSelected(typ string, key int) bool {
+	if SingleKey > 0 && SingleKey != key {
+		return false
+	}
+	if SingleType != "" && SingleType != typ {
+		return false
+	}
+	return true
+}
+
 type Meta struct {
 	Version    int    `json:"v"`
 	Expedition string `json:"e"`
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/drive/v3"
+)
+
+// The folders in a media source. In Drive each is a folder ID (see getMediaSource), and in a local directory each is
+// a subdirectory.
+const (
+	VideoMedia     = "videos"
+	ThumbnailMedia = "thumbnails"
+	CaptionMedia   = "captions"
+)
+
+// mediaFile is a video, thumbnail photo or caption file. Files are named the same way in every source (see
+// filenameConvention).
+type mediaFile struct {
+	Id       string // Drive file ID, or the path of a local file
+	Name     string
+	MimeType string
+	Size     int64
+	Md5      string // hex, only known for Drive files
+	Modified time.Time
+}
+
+// Local files get their MIME type from the extension. These are checked first because the system table often doesn't
+// have them.
+var localMimeTypes = map[string]string{
+	".mp4": "video/mp4",
+	".m4v": "video/x-m4v",
+	".mov": "video/quicktime",
+	".jpg": "image/jpeg",
+	".png": "image/png",
+	".srt": "application/x-subrip",
+	".vtt": "text/vtt",
+}
+
+// isMediaFile reports whether the file belongs in the folder: videos in VideoMedia, images in ThumbnailMedia and
+// SRT or WebVTT files in CaptionMedia. Anything else (e.g. .DS_Store or a Google Doc) is skipped rather than failing
+// the filename checks.
+func isMediaFile(folder string, file *mediaFile) bool {
+	if strings.HasPrefix(file.Name, ".") {
+		return false
+	}
+	switch folder {
+	case VideoMedia:
+		return strings.HasPrefix(file.MimeType, "video/")
+	case ThumbnailMedia:
+		return strings.HasPrefix(file.MimeType, "image/")
+	case CaptionMedia:
+		ext := strings.ToLower(filepath.Ext(file.Name))
+		return ext == ".srt" || ext == ".vtt"
+	}
+	return !strings.HasPrefix(file.MimeType, "application/vnd.google-apps.")
+}
+
+// mediaSource is where the videos, thumbnail photos and captions for an expedition come from.
+type mediaSource interface {
+	// List returns the media files in the folder (see isMediaFile), or none if the source doesn't have that folder.
+	List(folder string) ([]*mediaFile, error)
+	// Open returns the contents of the file, which the caller closes. Reading fails at the end if the contents don't
+	// match the size or checksum in the listing.
+	Open(file *mediaFile) (io.ReadCloser, error)
+}
+
+// getMediaSource returns the local directory for the expedition if one is set (GhtMediaDir, AntMediaDir), or its
+// Drive folders.
+func getMediaSource(ctx context.Context, expedition string) (mediaSource, error) {
+	var dir string
+	var folders map[string]string
+	switch expedition {
+	case "ght":
+		dir = GhtMediaDir
+		folders = map[string]string{VideoMedia: GhtVideoFolder, ThumbnailMedia: GhtThumbnailFolder, CaptionMedia: GhtCaptionFolder}
+	case "ant":
+		dir = AntMediaDir
+		folders = map[string]string{VideoMedia: AntVideoFolder, ThumbnailMedia: AntThumbnailFolder, CaptionMedia: AntCaptionFolder}
+	default:
+		return nil, fmt.Errorf("unknown expedition %q, should be ght or ant", expedition)
+	}
+	if dir != "" {
+		return &localSource{dir: dir, recursive: RecurseMediaFolders}, nil
+	}
+	driveService, err := getDriveService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("can't get drive service: %w", err)
+	}
+	return &driveSource{srv: driveService, folders: folders, recursive: RecurseMediaFolders}, nil
+}
+
+// driveSource lists files in Drive folders, by folder ID.
+type driveSource struct {
+	srv       *drive.Service
+	folders   map[string]string
+	recursive bool
+}
+
+func (s *driveSource) List(folder string) ([]*mediaFile, error) {
+	id := s.folders[folder]
+	if id == "" {
+		return nil, nil
+	}
+	files, err := getFilesInFolder(s.srv, id, s.recursive)
+	if err != nil {
+		return nil, err
+	}
+	var media []*mediaFile
+	for _, f := range files {
+		file := &mediaFile{Id: f.Id, Name: f.Name, MimeType: f.MimeType, Size: f.Size, Md5: f.Md5Checksum}
+		if f.ModifiedTime != "" {
+			file.Modified, err = time.Parse(time.RFC3339, f.ModifiedTime)
+			if err != nil {
+				return nil, fmt.Errorf("parsing modified time of %q: %w", f.Name, err)
+			}
+		}
+		if isMediaFile(folder, file) {
+			media = append(media, file)
+		}
+	}
+	return media, nil
+}
+
+// Open returns the file from the download cache (see openCached), downloading it if it's not there.
+func (s *driveSource) Open(file *mediaFile) (io.ReadCloser, error) {
+	return openCached(file, func() (io.ReadCloser, error) {
+		download, err := s.srv.Files.Get(file.Id).Download()
+		if err != nil {
+			return nil, fmt.Errorf("downloading drive file: %w", err)
+		}
+		return &verifyingReader{ReadCloser: download.Body, file: file, hash: md5.New()}, nil
+	})
+}
+
+// localSource lists files in subdirectories of a local directory.
+type localSource struct {
+	dir       string
+	recursive bool
+}
+
+func (s *localSource) List(folder string) ([]*mediaFile, error) {
+	dir := filepath.Join(s.dir, folder)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil, nil
+	}
+	var media []*mediaFile
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != dir && (!s.recursive || strings.HasPrefix(info.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		file := &mediaFile{Id: path, Name: info.Name(), MimeType: localMimeType(info.Name()), Size: info.Size(), Modified: info.ModTime()}
+		if isMediaFile(folder, file) {
+			media = append(media, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing %q: %w", dir, err)
+	}
+	return media, nil
+}
+
+func (s *localSource) Open(file *mediaFile) (io.ReadCloser, error) {
+	f, err := os.Open(file.Id)
+	if err != nil {
+		return nil, fmt.Errorf("opening local file: %w", err)
+	}
+	return &verifyingReader{ReadCloser: f, file: file}, nil
+}
+
+func localMimeType(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if t, ok := localMimeTypes[ext]; ok {
+		return t
+	}
+	t, _, _ := mime.ParseMediaType(mime.TypeByExtension(ext))
+	return t
+}
+
+// verifyingReader counts and hashes the file as it's read, and returns an error instead of io.EOF if it doesn't match
+// the size or MD5 checksum from the listing, so a truncated or corrupt download is never uploaded. A nil hash or
+// empty checksum skips the checksum.
+type verifyingReader struct {
+	io.ReadCloser
+	file *mediaFile
+	hash hash.Hash
+	read int64
+}
+
+func (r *verifyingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.read += int64(n)
+	if r.hash != nil {
+		r.hash.Write(p[:n])
+	}
+	if err != io.EOF {
+		return n, err
+	}
+	if r.file.Size > 0 && r.read != r.file.Size {
+		return n, fmt.Errorf("read %d bytes of %q, should be %d", r.read, r.file.Name, r.file.Size)
+	}
+	if r.hash != nil && r.file.Md5 != "" {
+		if sum := hex.EncodeToString(r.hash.Sum(nil)); sum != r.file.Md5 {
+			return n, fmt.Errorf("md5 of %q is %s, should be %s", r.file.Name, sum, r.file.Md5)
+		}
+	}
+	return n, err
+}
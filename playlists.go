@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+var PlaylistParts = []string{"id", "snippet", "status"}
+
+var sectionPlaylistTitleTemplate = template.Must(template.New("main").Parse(`The Great Himalaya Trail - {{ .Name }} Section`))
+
+var sectionPlaylistDescriptionTemplate = template.Must(template.New("main").Parse(`{{ "" -}}
+Days {{ .Min }} to {{ .Max }} of our thru-hike of the Great Himalaya Trail across Nepal: the {{ .Name }} section.
+
+Watch the whole series: https://www.youtube.com/playlist?list={{ .Series }}
+`))
+
+type sectionPlaylist struct {
+	Name     string
+	Min, Max int
+	Series   string
+	Items    []*GhtVideoData
+}
+
+// getGhtSections returns the GHT sections in order, with the days that have videos.
+func getGhtSections(data []*GhtVideoData) []*sectionPlaylist {
+	var sections []*sectionPlaylist
+	for _, item := range data {
+		if item.Expedition != "ght" || item.Type != "day" || item.Section == "" {
+			continue
+		}
+		if len(sections) == 0 || sections[len(sections)-1].Name != item.Section {
+			sections = append(sections, &sectionPlaylist{Name: item.Section, Min: item.Key, Series: GhtPlaylist})
+		}
+		s := sections[len(sections)-1]
+		s.Max = item.Key
+		if item.HasVideo {
+			s.Items = append(s.Items, item)
+		}
+	}
+	return sections
+}
+
+// ensureGhtSectionPlaylists creates a playlist for each section that doesn't have one yet (recording the ID in the
+// state), and updates the title and description of the existing ones.
+func ensureGhtSectionPlaylists(srv *youtube.Service, state *State, data []*GhtVideoData) error {
+
+	existing, err := getMyPlaylists(srv)
+	if err != nil {
+		return fmt.Errorf("getting playlists: %w", err)
+	}
+
+	for _, section := range getGhtSections(data) {
+
+		buf := bytes.NewBufferString("")
+		if err := sectionPlaylistTitleTemplate.Execute(buf, section); err != nil {
+			return fmt.Errorf("executing playlist title template: %w", err)
+		}
+		title := buf.String()
+
+		buf = bytes.NewBufferString("")
+		if err := sectionPlaylistDescriptionTemplate.Execute(buf, section); err != nil {
+			return fmt.Errorf("executing playlist description template: %w", err)
+		}
+		description := buf.String()
+
+		playlist := existing[state.Playlists[section.Name]]
+
+		if playlist == nil {
+			fmt.Printf("Inserting playlist: %q\n", title)
+			playlist = &youtube.Playlist{
+				Snippet: &youtube.PlaylistSnippet{
+					Title:           title,
+					Description:     description,
+					DefaultLanguage: "en",
+				},
+				Status: &youtube.PlaylistStatus{
+					PrivacyStatus: "public",
+				},
+			}
+			inserted, err := srv.Playlists.Insert(PlaylistParts, playlist).Do()
+			if err != nil {
+				return fmt.Errorf("inserting playlist: %w", err)
+			}
+			state.Playlists[section.Name] = inserted.Id
+			if err := state.Save(); err != nil {
+				return fmt.Errorf("saving state: %w", err)
+			}
+			continue
+		}
+
+		if playlist.Snippet.Title == title && playlist.Snippet.Description == description {
+			continue
+		}
+
+		fmt.Printf("Updating playlist: %q\n", title)
+		playlist.Snippet.Title = title
+		playlist.Snippet.Description = description
+		if _, err := srv.Playlists.Update(PlaylistParts, playlist).Do(); err != nil {
+			return fmt.Errorf("updating playlist: %w", err)
+		}
+	}
+	return nil
+}
+
+// syncGhtSectionPlaylists makes sure each section playlist contains exactly the videos in the section, in order.
+func syncGhtSectionPlaylists(srv *youtube.Service, state *State, data []*GhtVideoData) error {
+	for _, section := range getGhtSections(data) {
+		id := state.Playlists[section.Name]
+		if id == "" {
+			continue
+		}
+		var videoIds []string
+		for _, item := range section.Items {
+			if item.Video != nil && item.Video.Id != "" {
+				videoIds = append(videoIds, item.Video.Id)
+			}
+		}
+		fmt.Printf("Syncing playlist for the %s section\n", section.Name)
+		if err := syncPlaylist(srv, id, videoIds); err != nil {
+			return fmt.Errorf("syncing playlist for %s section: %w", section.Name, err)
+		}
+	}
+	return nil
+}
+
+// getMyPlaylists returns all the playlists on the channel, by ID.
+func getMyPlaylists(srv *youtube.Service) (map[string]*youtube.Playlist, error) {
+	all := map[string]*youtube.Playlist{}
+
+	var done bool
+	var pageToken string
+
+	for !done {
+
+		response, err := srv.Playlists.List(PlaylistParts).Mine(true).MaxResults(50).PageToken(pageToken).Do()
+		if err != nil {
+			return nil, fmt.Errorf("youtube playlist list call: %w", err)
+		}
+
+		for _, v := range response.Items {
+			all[v.Id] = v
+		}
+
+		pageToken = response.NextPageToken
+		if pageToken == "" {
+			done = true
+		}
+	}
+
+	return all, nil
+}
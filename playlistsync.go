@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// ForeignPlaylistItems decides what happens to videos in a managed playlist that aren't in the data: "keep" moves
+// them to the end of the playlist, "remove" deletes them and "error" stops the sync.
+const ForeignPlaylistItems = "keep"
+
+type playlistOp struct {
+	Action   string // "delete", "insert" or "move"
+	VideoId  string
+	Item     *youtube.PlaylistItem // the existing item (delete and move only)
+	Position int64                 // the position after the operation (insert and move only)
+}
+
+func (op playlistOp) String() string {
+	switch op.Action {
+	case "delete":
+		return fmt.Sprintf("delete %s", op.VideoId)
+	default:
+		return fmt.Sprintf("%s %s at position %d", op.Action, op.VideoId, op.Position)
+	}
+}
+
+// planPlaylistSync works out the operations needed to turn the current playlist into videoIds (followed by any
+// foreign videos we keep). Only the videos outside the longest run that is already in the right relative order are
+// moved, so a playlist that is already in order needs no operations at all. The operations must be executed in
+// order, because the positions take the earlier operations into account.
+func planPlaylistSync(current []*youtube.PlaylistItem, videoIds []string, foreign string) ([]playlistOp, error) {
+	var ops []playlistOp
+
+	rank := map[string]int{}
+	for i, id := range videoIds {
+		rank[id] = i
+	}
+
+	// Delete duplicates and (depending on the policy) foreign videos. The rest get a rank: the position in videoIds,
+	// or after all of them for foreign videos we keep.
+	type entry struct {
+		id   string
+		item *youtube.PlaylistItem
+		rank int
+	}
+	var live []*entry
+	seen := map[string]bool{}
+	var foreignCount int
+	for _, item := range current {
+		id := item.ContentDetails.VideoId
+		r, wanted := rank[id]
+		switch {
+		case seen[id]:
+			ops = append(ops, playlistOp{Action: "delete", VideoId: id, Item: item})
+			continue
+		case !wanted:
+			switch foreign {
+			case "keep":
+				r = len(videoIds) + foreignCount
+				foreignCount++
+			case "remove":
+				ops = append(ops, playlistOp{Action: "delete", VideoId: id, Item: item})
+				continue
+			default:
+				return nil, fmt.Errorf("video %s is in the playlist but not in the data", id)
+			}
+		}
+		seen[id] = true
+		live = append(live, &entry{id: id, item: item, rank: r})
+	}
+
+	// The entries in the longest increasing run of ranks stay where they are.
+	stable := map[*entry]bool{}
+	for _, i := range longestIncreasing(len(live), func(i int) int { return live[i].rank }) {
+		stable[live[i]] = true
+	}
+
+	// Everything else is placed directly after its predecessor in the final order, working from the start. The
+	// stable entries never change relative order, so once every entry has been placed the whole playlist is in order.
+	var final []*entry
+	byId := map[string]*entry{}
+	for _, e := range live {
+		byId[e.id] = e
+	}
+	for _, id := range videoIds {
+		if e, ok := byId[id]; ok {
+			final = append(final, e)
+		} else {
+			final = append(final, &entry{id: id, rank: rank[id]})
+		}
+	}
+	var foreignEntries []*entry
+	for _, e := range live {
+		if e.rank >= len(videoIds) {
+			foreignEntries = append(foreignEntries, e)
+		}
+	}
+	final = append(final, foreignEntries...)
+
+	indexOf := func(e *entry) int {
+		for i, l := range live {
+			if l == e {
+				return i
+			}
+		}
+		return -1
+	}
+
+	for i, e := range final {
+		if stable[e] {
+			continue
+		}
+		position := 0
+		if i > 0 {
+			position = indexOf(final[i-1]) + 1
+		}
+		index := indexOf(e)
+		if index == -1 {
+			ops = append(ops, playlistOp{Action: "insert", VideoId: e.id, Position: int64(position)})
+		} else {
+			live = append(live[:index], live[index+1:]...)
+			if index < position {
+				position--
+			}
+			if index == position {
+				live = append(live[:position], append([]*entry{e}, live[position:]...)...)
+				continue
+			}
+			ops = append(ops, playlistOp{Action: "move", VideoId: e.id, Item: e.item, Position: int64(position)})
+		}
+		live = append(live[:position], append([]*entry{e}, live[position:]...)...)
+	}
+
+	return ops, nil
+}
+
+// longestIncreasing returns the indexes of the longest strictly increasing subsequence of the n values.
+func longestIncreasing(n int, value func(i int) int) []int {
+	var tails []int // tails[k] is the index of the smallest tail of an increasing subsequence of length k+1
+	prev := make([]int, n)
+	for i := 0; i < n; i++ {
+		k := sort.Search(len(tails), func(k int) bool { return value(tails[k]) >= value(i) })
+		if k > 0 {
+			prev[i] = tails[k-1]
+		} else {
+			prev[i] = -1
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+	result := make([]int, len(tails))
+	if len(tails) == 0 {
+		return result
+	}
+	for i, k := tails[len(tails)-1], len(tails)-1; k >= 0; i, k = prev[i], k-1 {
+		result[k] = i
+	}
+	return result
+}
+
+// syncPlaylist makes the playlist contain videoIds in that order, printing the plan before executing it.
+func syncPlaylist(srv *youtube.Service, playlistId string, videoIds []string) error {
+	items, err := getPlaylist(srv, playlistId)
+	if err != nil {
+		return fmt.Errorf("getting playlist items: %w", err)
+	}
+
+	ops, err := planPlaylistSync(items, videoIds, ForeignPlaylistItems)
+	if err != nil {
+		return fmt.Errorf("planning playlist sync: %w", err)
+	}
+
+	if len(ops) == 0 {
+		fmt.Println("Playlist is already in sync")
+		return nil
+	}
+	fmt.Printf("Playlist sync needs %d operations:\n", len(ops))
+	for _, op := range ops {
+		fmt.Printf(" - %s\n", op)
+	}
+
+	for _, op := range ops {
+		switch op.Action {
+		case "delete":
+			if err := srv.PlaylistItems.Delete(op.Item.Id).Do(); err != nil {
+				return fmt.Errorf("deleting playlist item: %w", err)
+			}
+		case "insert":
+			item := &youtube.PlaylistItem{
+				Snippet: &youtube.PlaylistItemSnippet{
+					PlaylistId: playlistId,
+					Position:   op.Position,
+					ResourceId: &youtube.ResourceId{Kind: "youtube#video", VideoId: op.VideoId},
+				},
+			}
+			if _, err := srv.PlaylistItems.Insert(PlaylistItemParts, item).Do(); err != nil {
+				return fmt.Errorf("inserting playlist item: %w", err)
+			}
+		case "move":
+			op.Item.Snippet.Position = op.Position
+			if _, err := srv.PlaylistItems.Update(PlaylistItemParts, op.Item).Do(); err != nil {
+				return fmt.Errorf("updating playlist item: %w", err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+func TestPlanPlaylistSync(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		current string // video IDs in the playlist, space separated
+		want    string // video IDs in the data
+		foreign string
+		result  string // the playlist after the operations
+		ops     int
+		err     bool
+	}{
+		{name: "sorted", current: "a b c d", want: "a b c d", foreign: "keep", result: "a b c d", ops: 0},
+		{name: "reversed", current: "d c b a", want: "a b c d", foreign: "keep", result: "a b c d", ops: 3},
+		{name: "one moved", current: "b c a d", want: "a b c d", foreign: "keep", result: "a b c d", ops: 1},
+		{name: "insert only", current: "a c", want: "a b c d", foreign: "keep", result: "a b c d", ops: 2},
+		{name: "insert into empty", current: "", want: "a b", foreign: "keep", result: "a b", ops: 2},
+		{name: "delete duplicates", current: "a b b c a", want: "a b c", foreign: "keep", result: "a b c", ops: 2},
+		{name: "foreign keep", current: "x a b", want: "a b", foreign: "keep", result: "a b x", ops: 1},
+		{name: "foreign keep in order", current: "a x b y", want: "a b", foreign: "keep", result: "a b x y", ops: 1},
+		{name: "foreign remove", current: "x a y b", want: "a b", foreign: "remove", result: "a b", ops: 2},
+		{name: "foreign error", current: "a x b", want: "a b", foreign: "error", err: true},
+		{name: "no foreign error", current: "b a", want: "a b", foreign: "error", result: "a b", ops: 1},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			current := testPlaylistItems(test.current)
+			ops, err := planPlaylistSync(current, strings.Fields(test.want), test.foreign)
+			if test.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", ops)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := applyPlaylistOps(t, current, ops)
+			if strings.Join(result, " ") != test.result {
+				t.Errorf("playlist is %q after %v, expected %q", strings.Join(result, " "), ops, test.result)
+			}
+			if len(ops) != test.ops {
+				t.Errorf("%d operations %v, expected %d", len(ops), ops, test.ops)
+			}
+		})
+	}
+}
+
+func testPlaylistItems(ids string) []*youtube.PlaylistItem {
+	var items []*youtube.PlaylistItem
+	for _, id := range strings.Fields(ids) {
+		items = append(items, &youtube.PlaylistItem{ContentDetails: &youtube.PlaylistItemContentDetails{VideoId: id}})
+	}
+	return items
+}
+
+// applyPlaylistOps does what YouTube would do with the operations, and returns the video IDs in the playlist.
+func applyPlaylistOps(t *testing.T, current []*youtube.PlaylistItem, ops []playlistOp) []string {
+	items := append([]*youtube.PlaylistItem(nil), current...)
+	indexOf := func(item *youtube.PlaylistItem) int {
+		for i, it := range items {
+			if it == item {
+				return i
+			}
+		}
+		t.Fatalf("item %s isn't in the playlist", item.ContentDetails.VideoId)
+		return -1
+	}
+	insert := func(item *youtube.PlaylistItem, position int64) {
+		if position < 0 || int(position) > len(items) {
+			t.Fatalf("position %d is outside the playlist of %d", position, len(items))
+		}
+		items = append(items[:position], append([]*youtube.PlaylistItem{item}, items[position:]...)...)
+	}
+	for _, op := range ops {
+		switch op.Action {
+		case "delete":
+			i := indexOf(op.Item)
+			items = append(items[:i], items[i+1:]...)
+		case "insert":
+			insert(&youtube.PlaylistItem{ContentDetails: &youtube.PlaylistItemContentDetails{VideoId: op.VideoId}}, op.Position)
+		case "move":
+			i := indexOf(op.Item)
+			items = append(items[:i], items[i+1:]...)
+			insert(op.Item, op.Position)
+		default:
+			t.Fatalf("unknown action %q", op.Action)
+		}
+	}
+	ids := []string{}
+	for _, item := range items {
+		ids = append(ids, item.ContentDetails.VideoId)
+	}
+	return ids
+}
+
+func TestLongestIncreasing(t *testing.T) {
+	for _, test := range []struct {
+		values []int
+		want   []int
+	}{
+		{values: nil, want: []int{}},
+		{values: []int{5}, want: []int{0}},
+		{values: []int{1, 2, 3, 4}, want: []int{0, 1, 2, 3}},
+		{values: []int{4, 3, 2, 1}, want: []int{3}},
+		{values: []int{2, 2, 2}, want: []int{2}},
+		{values: []int{3, 1, 2, 0, 4}, want: []int{1, 2, 4}},
+		{values: []int{0, 8, 4, 12, 2, 10, 6, 14, 1, 9}, want: []int{0, 4, 6, 9}},
+	} {
+		got := longestIncreasing(len(test.values), func(i int) int { return test.values[i] })
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("longestIncreasing(%v) = %v, expected %v", test.values, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+const ProcessingTimeout = 30 * time.Minute
+
+// ProcessingPollInterval is a variable so the tests can poll quickly.
+var ProcessingPollInterval = 30 * time.Second
+
+var ProcessingParts = []string{"id", "snippet", "status", "processingDetails", "fileDetails"}
+
+// processingResult is the outcome of an upload: "processed", "failed" (including rejected and deleted) or
+// "processing" if it hadn't finished by the timeout.
+type processingResult struct {
+	VideoId string
+	Title   string
+	Result  string
+	Reason  string
+	File    string // a summary of the file details, when available
+}
+
+// processingCommand watches the given videos until YouTube has processed them, or with no IDs, every managed video
+// that hasn't finished processing.
+func processingCommand(ctx context.Context, args []string) error {
+	youtubeService, err := getYoutubeService(ctx)
+	if err != nil {
+		return fmt.Errorf("getting youtube service: %w", err)
+	}
+
+	videoIds := args
+	if len(videoIds) == 0 {
+		episodes, err := getManagedEpisodes(youtubeService)
+		if err != nil {
+			return fmt.Errorf("getting episodes: %w", err)
+		}
+		for _, e := range episodes {
+			if e.Video.Status == nil || e.Video.Status.UploadStatus != "processed" {
+				videoIds = append(videoIds, e.VideoId)
+			}
+		}
+		if len(videoIds) == 0 {
+			fmt.Println("All videos have been processed")
+			return nil
+		}
+	}
+
+	return watchProcessing(youtubeService, videoIds, ProcessingTimeout)
+}
+
+// watchProcessing polls the videos until they've all finished processing or the timeout is reached, then prints a
+// report. It returns an error if any video failed, was rejected or is still processing.
+func watchProcessing(srv *youtube.Service, videoIds []string, timeout time.Duration) error {
+	fmt.Printf("Watching %d videos for processing\n", len(videoIds))
+
+	results := map[string]*processingResult{}
+	pending := append([]string{}, videoIds...)
+	deadline := time.Now().Add(timeout)
+
+	for {
+		videos, err := getVideosById(srv, pending)
+		if err != nil {
+			return err
+		}
+		found := map[string]bool{}
+		var next []string
+		for _, v := range videos {
+			found[v.Id] = true
+			result := getProcessingResult(v)
+			results[v.Id] = result
+			if result.Result == "processing" {
+				next = append(next, v.Id)
+				fmt.Printf(" - %s: %s\n", result.Title, processingProgress(v))
+			}
+		}
+		for _, id := range pending {
+			if !found[id] {
+				results[id] = &processingResult{VideoId: id, Result: "failed", Reason: "video not found"}
+			}
+		}
+		pending = next
+		if len(pending) == 0 || time.Now().Add(ProcessingPollInterval).After(deadline) {
+			break
+		}
+		time.Sleep(ProcessingPollInterval)
+	}
+
+	var ordered []*processingResult
+	for _, id := range videoIds {
+		ordered = append(ordered, results[id])
+	}
+	if err := writeProcessingReport(os.Stdout, ordered); err != nil {
+		return fmt.Errorf("writing processing report: %w", err)
+	}
+
+	var problems []string
+	for _, result := range ordered {
+		if result.Result != "processed" {
+			problems = append(problems, fmt.Sprintf("%s (%s)", result.VideoId, result.Result))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d videos didn't process: %s", len(problems), strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+func getVideosById(srv *youtube.Service, videoIds []string) ([]*youtube.Video, error) {
+	var all []*youtube.Video
+	for len(videoIds) > 0 {
+		batch := videoIds
+		if len(batch) > 50 {
+			batch = batch[:50]
+		}
+		videoIds = videoIds[len(batch):]
+		response, err := srv.Videos.List(ProcessingParts).Id(strings.Join(batch, ",")).Do()
+		if err != nil {
+			return nil, fmt.Errorf("youtube videos list call: %w", err)
+		}
+		all = append(all, response.Items...)
+	}
+	return all, nil
+}
+
+func getProcessingResult(v *youtube.Video) *processingResult {
+	result := &processingResult{VideoId: v.Id, Result: "processing"}
+	if v.Snippet != nil {
+		result.Title = v.Snippet.Title
+	}
+	if v.FileDetails != nil {
+		result.File = describeFileDetails(v.FileDetails)
+	}
+
+	if v.Status != nil {
+		switch v.Status.UploadStatus {
+		case "processed":
+			result.Result = "processed"
+		case "failed":
+			result.Result = "failed"
+			result.Reason = "upload failed: " + v.Status.FailureReason
+		case "rejected":
+			result.Result = "failed"
+			result.Reason = "rejected: " + v.Status.RejectionReason
+		case "deleted":
+			result.Result = "failed"
+			result.Reason = "deleted"
+		}
+	}
+
+	if d := v.ProcessingDetails; d != nil && result.Result == "processing" {
+		switch d.ProcessingStatus {
+		case "failed", "terminated":
+			result.Result = "failed"
+			result.Reason = "processing " + d.ProcessingStatus
+			if d.ProcessingFailureReason != "" {
+				result.Reason += ": " + d.ProcessingFailureReason
+			}
+		}
+	}
+	return result
+}
+
+func processingProgress(v *youtube.Video) string {
+	d := v.ProcessingDetails
+	if d == nil || d.ProcessingProgress == nil || d.ProcessingProgress.PartsTotal == 0 {
+		return "processing"
+	}
+	p := d.ProcessingProgress
+	s := fmt.Sprintf("processing %d%%", 100*p.PartsProcessed/p.PartsTotal)
+	if p.TimeLeftMs > 0 {
+		s += fmt.Sprintf(", %s left", (time.Duration(p.TimeLeftMs) * time.Millisecond).Round(time.Second))
+	}
+	return s
+}
+
+func describeFileDetails(f *youtube.VideoFileDetails) string {
+	var parts []string
+	if f.FileName != "" {
+		parts = append(parts, f.FileName)
+	}
+	if f.FileSize > 0 {
+		parts = append(parts, fmt.Sprintf("%.1f MB", float64(f.FileSize)/1e6))
+	}
+	if f.DurationMs > 0 {
+		parts = append(parts, (time.Duration(f.DurationMs) * time.Millisecond).Round(time.Second).String())
+	}
+	if len(f.VideoStreams) > 0 {
+		s := f.VideoStreams[0]
+		parts = append(parts, fmt.Sprintf("%dx%d %.0ffps", s.WidthPixels, s.HeightPixels, s.FrameRateFps))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func writeProcessingReport(w io.Writer, results []*processingResult) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VIDEO\tRESULT\tREASON\tFILE\tTITLE")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.VideoId, r.Result, r.Reason, r.File, r.Title)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/option"
+	"google.golang.org/api/youtube/v3"
+)
+
+func TestGetProcessingResult(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		video  *youtube.Video
+		result string
+		reason string
+	}{
+		{
+			name:   "processed",
+			video:  &youtube.Video{Status: &youtube.VideoStatus{UploadStatus: "processed"}},
+			result: "processed",
+		},
+		{
+			name:   "uploaded",
+			video:  &youtube.Video{Status: &youtube.VideoStatus{UploadStatus: "uploaded"}, ProcessingDetails: &youtube.VideoProcessingDetails{ProcessingStatus: "processing"}},
+			result: "processing",
+		},
+		{
+			name:   "no status",
+			video:  &youtube.Video{},
+			result: "processing",
+		},
+		{
+			name:   "upload failed",
+			video:  &youtube.Video{Status: &youtube.VideoStatus{UploadStatus: "failed", FailureReason: "codec"}},
+			result: "failed",
+			reason: "upload failed: codec",
+		},
+		{
+			name:   "rejected",
+			video:  &youtube.Video{Status: &youtube.VideoStatus{UploadStatus: "rejected", RejectionReason: "duplicate"}},
+			result: "failed",
+			reason: "rejected: duplicate",
+		},
+		{
+			name:   "deleted",
+			video:  &youtube.Video{Status: &youtube.VideoStatus{UploadStatus: "deleted"}},
+			result: "failed",
+			reason: "deleted",
+		},
+		{
+			name:   "processing failed",
+			video:  &youtube.Video{Status: &youtube.VideoStatus{UploadStatus: "uploaded"}, ProcessingDetails: &youtube.VideoProcessingDetails{ProcessingStatus: "failed", ProcessingFailureReason: "transcodeFailed"}},
+			result: "failed",
+			reason: "processing failed: transcodeFailed",
+		},
+		{
+			name:   "processing terminated",
+			video:  &youtube.Video{Status: &youtube.VideoStatus{UploadStatus: "uploaded"}, ProcessingDetails: &youtube.VideoProcessingDetails{ProcessingStatus: "terminated"}},
+			result: "failed",
+			reason: "processing terminated",
+		},
+		{
+			name:   "upload status wins",
+			video:  &youtube.Video{Status: &youtube.VideoStatus{UploadStatus: "rejected", RejectionReason: "length"}, ProcessingDetails: &youtube.VideoProcessingDetails{ProcessingStatus: "terminated"}},
+			result: "failed",
+			reason: "rejected: length",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			test.video.Id = "abc"
+			test.video.Snippet = &youtube.VideoSnippet{Title: "Day 12"}
+			result := getProcessingResult(test.video)
+			if result.VideoId != "abc" || result.Title != "Day 12" {
+				t.Errorf("got video %q %q", result.VideoId, result.Title)
+			}
+			if result.Result != test.result || result.Reason != test.reason {
+				t.Errorf("got %s %q, expected %s %q", result.Result, result.Reason, test.result, test.reason)
+			}
+		})
+	}
+}
+
+func TestDescribeFileDetails(t *testing.T) {
+	details := &youtube.VideoFileDetails{
+		FileName:     "D012.mp4",
+		FileSize:     1234567890,
+		DurationMs:   754400,
+		VideoStreams: []*youtube.VideoFileDetailsVideoStream{{WidthPixels: 3840, HeightPixels: 2160, FrameRateFps: 25}},
+	}
+	if got, want := describeFileDetails(details), "D012.mp4, 1234.6 MB, 12m34s, 3840x2160 25fps"; got != want {
+		t.Errorf("got %q, expected %q", got, want)
+	}
+}
+
+func TestWriteProcessingReport(t *testing.T) {
+	buf := &bytes.Buffer{}
+	results := []*processingResult{
+		{VideoId: "a", Title: "Day 1", Result: "processed", File: "D001.mp4"},
+		{VideoId: "b", Title: "Day 2", Result: "failed", Reason: "rejected: duplicate"},
+	}
+	if err := writeProcessingReport(buf, results); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[2], "b") || !strings.Contains(lines[2], "rejected: duplicate") {
+		t.Fatalf("unexpected report:\n%s", buf.String())
+	}
+}
+
+// processingStandIn serves the videos by ID, calling update before each list so they can change between polls.
+func processingStandIn(t *testing.T, videos map[string]*youtube.Video, update func(polls int)) *youtube.Service {
+	var polls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		update(polls)
+		response := &youtube.VideoListResponse{}
+		for _, id := range strings.Split(r.URL.Query().Get("id"), ",") {
+			if v, ok := videos[id]; ok {
+				response.Items = append(response.Items, v)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+	srv, err := youtube.NewService(context.Background(), option.WithEndpoint(server.URL+"/"), option.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+func testPollInterval(t *testing.T) {
+	old := ProcessingPollInterval
+	ProcessingPollInterval = time.Millisecond
+	t.Cleanup(func() { ProcessingPollInterval = old })
+}
+
+func TestWatchProcessing(t *testing.T) {
+	testPollInterval(t)
+	videos := map[string]*youtube.Video{
+		"a": {Id: "a", Status: &youtube.VideoStatus{UploadStatus: "uploaded"}},
+		"b": {Id: "b", Status: &youtube.VideoStatus{UploadStatus: "uploaded"}},
+	}
+	srv := processingStandIn(t, videos, func(polls int) {
+		// a finishes on the second poll and b on the third
+		if polls == 2 {
+			videos["a"].Status.UploadStatus = "processed"
+		}
+		if polls == 3 {
+			videos["b"].Status.UploadStatus = "processed"
+		}
+	})
+	if err := watchProcessing(srv, []string{"a", "b"}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatchProcessingTimeout(t *testing.T) {
+	testPollInterval(t)
+	videos := map[string]*youtube.Video{
+		"a": {Id: "a", Status: &youtube.VideoStatus{UploadStatus: "processed"}},
+		"b": {Id: "b", Status: &youtube.VideoStatus{UploadStatus: "uploaded"}, ProcessingDetails: &youtube.VideoProcessingDetails{
+			ProcessingStatus:   "processing",
+			ProcessingProgress: &youtube.VideoProcessingDetailsProcessingProgress{PartsProcessed: 1, PartsTotal: 4},
+		}},
+	}
+	var polls int
+	srv := processingStandIn(t, videos, func(p int) { polls = p })
+	err := watchProcessing(srv, []string{"a", "b", "missing"}, 20*time.Millisecond)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"2 videos didn't process", "b (processing)", "missing (failed)"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't contain %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "a (") {
+		t.Errorf("error %q includes the processed video", err)
+	}
+	if polls < 2 {
+		t.Errorf("polled %d times, expected to keep polling until the timeout", polls)
+	}
+}
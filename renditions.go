@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const RenditionOutputDir = "./renditions"
+const RenditionQuality = 90
+
+// Set a Drive folder ID to also save the renditions there for the team and the website, named by key and rendition
+// (e.g. D012_square.jpg). When the upload workflow uploads a new thumbnail it saves every rendition, with the YouTube
+// thumbnail exactly as it was uploaded.
+const GhtRenditionFolder = ""
+const AntRenditionFolder = ""
+
+// renditionSource is a photo in the thumbnails folder, matched to its item.
+type renditionSource struct {
+	Key       int
+	Type      string
+	Crop      string
+	Adjust    string
+	Title     string
+	HasVideo  bool
+	Filename  string // meta data filename, for the crops in the state
+	Item      interface{}
+	Photo     string // name of the photo in the thumbnails folder
+	PhotoFile *mediaFile
+}
+
+// renditionsCommand renders every rendition of the thumbnail photos into RenditionOutputDir, e.g.
+// renditions/ght/square/D012.jpg, and the Drive rendition folder if there is one. With a key, only that day is
+// rendered.
+func renditionsCommand(ctx context.Context, args []string) error {
+	expedition := "ght"
+	key := -1
+	for _, arg := range args {
+		switch arg {
+		case "ght", "ant":
+			expedition = arg
+		default:
+			k, err := strconv.Atoi(arg)
+			if err != nil {
+				return fmt.Errorf("unexpected argument %q", arg)
+			}
+			key = k
+		}
+	}
+
+	media, err := getMediaSource(ctx, expedition)
+	if err != nil {
+		return fmt.Errorf("getting media source: %w", err)
+	}
+
+	layout, items, err := getRenditionSources(media, expedition)
+	if err != nil {
+		return err
+	}
+
+	state, err := loadState()
+	if err != nil {
+		return fmt.Errorf("loading state: %w", err)
+	}
+
+	writer, err := getRenditionWriter(ctx, expedition)
+	if err != nil {
+		return err
+	}
+	convention, err := getFilenameConvention(expedition)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if item.PhotoFile == nil || (key > -1 && item.Key != key) {
+			continue
+		}
+
+		images, err := renderSource(media, state, layout, item)
+		if err != nil {
+			return err
+		}
+
+		if err := writeRenditions(filepath.Join(RenditionOutputDir, expedition), item.Photo, images); err != nil {
+			return err
+		}
+		if writer != nil {
+			base, err := convention.Format(item.Type, item.Key)
+			if err != nil {
+				return err
+			}
+			fmt.Println("Saving renditions to drive", item.Photo)
+			if err := saveRenditions(writer, layout, base, item.PhotoFile, images); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// renderSource downloads the item's photo and renders every rendition of it (see renderPhoto).
+func renderSource(media mediaSource, state *State, layout *thumbnailLayout, item *renditionSource) (map[string]*image.NRGBA, error) {
+	fmt.Println("Downloading thumbnail", item.Photo)
+	download, err := media.Open(item.PhotoFile)
+	if err != nil {
+		return nil, fmt.Errorf("opening thumbnail: %w", err)
+	}
+	img, err := decodePhoto(download)
+	download.Close()
+	if err != nil {
+		return nil, fmt.Errorf("decoding %q: %w", item.Photo, err)
+	}
+	return renderPhoto(state, layout, append([]*thumbnailLayout{layout}, layout.Renditions...), item, img)
+}
+
+// renderPhoto renders the item's photo with each of the layouts, which are the thumbnail layout or its renditions,
+// keeping the automatic crops in the state. The youtube rendition shares its crop with the uploaded thumbnail.
+func renderPhoto(state *State, layout *thumbnailLayout, layouts []*thumbnailLayout, item *renditionSource, img image.Image) (map[string]*image.NRGBA, error) {
+	images := map[string]*image.NRGBA{}
+	var changed bool
+	for _, l := range layouts {
+		key := renditionCropKey(item.Filename, layout, l.Name)
+		crop := state.Crops[key]
+		rgba, err := renderThumbnail(l, item.Item, item.Type, item.Crop, item.Adjust, img, &crop)
+		if err != nil {
+			return nil, fmt.Errorf("rendering %s rendition of %q: %w", l.Name, item.Photo, err)
+		}
+		if crop != state.Crops[key] {
+			state.Crops[key] = crop
+			changed = true
+		}
+		images[l.Name] = rgba
+	}
+	if changed {
+		if err := state.Save(); err != nil {
+			return nil, fmt.Errorf("saving state: %w", err)
+		}
+	}
+	return images, nil
+}
+
+// saveUploadedRenditions is used by the upload workflow after it uploads a new thumbnail: it saves the thumbnail to
+// the Drive rendition folder exactly as it was uploaded, and renders the other renditions from the same decoded photo.
+func saveUploadedRenditions(writer *driveFolderWriter, state *State, layout *thumbnailLayout, convention *filenameConvention, item *renditionSource, img image.Image, b []byte, contentType string) error {
+	base, err := convention.Format(item.Type, item.Key)
+	if err != nil {
+		return err
+	}
+	images, err := renderPhoto(state, layout, layout.Renditions, item, img)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Saving renditions to drive", item.Photo)
+	if err := saveRendition(writer, base, item.PhotoFile, layout.Name, b, contentType); err != nil {
+		return err
+	}
+	return saveRenditions(writer, layout, base, item.PhotoFile, images)
+}
+
+// getRenditionSources loads the layout and the items for the expedition, and matches them to the photos in the
+// thumbnails folder. Items without a photo are returned with a nil PhotoFile.
+func getRenditionSources(media mediaSource, expedition string) (*thumbnailLayout, []*renditionSource, error) {
+	var layout *thumbnailLayout
+	var items []*renditionSource
+	var err error
+	switch expedition {
+	case "ght":
+		layout, err = loadThumbnailLayout(GhtThumbnailLayout)
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := getGhtData()
+		if err != nil {
+			return nil, nil, fmt.Errorf("can't load days: %w", err)
+		}
+		if err := ghtUpdateAllStrings(data); err != nil {
+			return nil, nil, fmt.Errorf("updating all strings: %w", err)
+		}
+		for _, item := range data {
+			items = append(items, &renditionSource{Key: item.Key, Type: item.Type, Crop: item.Crop, Adjust: item.Adjust, Title: item.FullTitle, HasVideo: item.HasVideo, Filename: item.MustGetFilename(), Item: item})
+		}
+	case "ant":
+		layout, err = loadThumbnailLayout(AntThumbnailLayout)
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := getAntData()
+		if err != nil {
+			return nil, nil, fmt.Errorf("can't load days: %w", err)
+		}
+		if err := antUpdateAllStrings(data); err != nil {
+			return nil, nil, fmt.Errorf("updating all strings: %w", err)
+		}
+		for _, item := range data {
+			items = append(items, &renditionSource{Key: item.Key, Type: item.Type, Crop: item.Crop, Adjust: item.Adjust, Title: item.FullTitle, HasVideo: true, Filename: item.MustGetFilename(), Item: item})
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown expedition %q, should be ght or ant", expedition)
+	}
+
+	convention, err := getFilenameConvention(expedition)
+	if err != nil {
+		return nil, nil, err
+	}
+	files, superseded, err := convention.List(media, ThumbnailMedia)
+	if err != nil {
+		return nil, nil, err
+	}
+	reportSuperseded(ThumbnailMedia, superseded)
+	for _, f := range files {
+		for _, item := range items {
+			if item.Type == f.Type && item.Key == f.Key {
+				item.Photo = f.Name
+				item.PhotoFile = f.mediaFile
+				break
+			}
+		}
+	}
+	return layout, items, nil
+}
+
+func renditionCropKey(filename string, layout *thumbnailLayout, name string) string {
+	if name == layout.Name {
+		return filename
+	}
+	return filename + "/" + name
+}
+
+// writeRenditions writes each image as a JPEG named after the photo, in a folder for the rendition.
+func writeRenditions(dir, photo string, images map[string]*image.NRGBA) error {
+	base := strings.TrimSuffix(photo, filepath.Ext(photo)) + ".jpg"
+	for name, img := range images {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0777); err != nil {
+			return fmt.Errorf("creating rendition folder: %w", err)
+		}
+		b, err := encodeRendition(img)
+		if err != nil {
+			return fmt.Errorf("encoding %s rendition: %w", name, err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name, base), b, 0666); err != nil {
+			return fmt.Errorf("writing %s rendition: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func encodeRendition(img image.Image) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: RenditionQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// getRenditionWriter returns a writer for the expedition's Drive rendition folder, or nil if it doesn't have one.
+func getRenditionWriter(ctx context.Context, expedition string) (*driveFolderWriter, error) {
+	folder := map[string]string{"ght": GhtRenditionFolder, "ant": AntRenditionFolder}[expedition]
+	if folder == "" {
+		return nil, nil
+	}
+	driveService, err := getDriveService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("can't get drive service: %w", err)
+	}
+	writer, err := newDriveFolderWriter(driveService, folder)
+	if err != nil {
+		return nil, fmt.Errorf("getting rendition folder: %w", err)
+	}
+	return writer, nil
+}
+
+// saveRenditions saves each image to the Drive rendition folder. The YouTube rendition is encoded exactly as it would
+// be uploaded, so it matches what the upload workflow saves.
+func saveRenditions(writer *driveFolderWriter, layout *thumbnailLayout, base string, photo *mediaFile, images map[string]*image.NRGBA) error {
+	for name, img := range images {
+		var b []byte
+		contentType := "image/jpeg"
+		var err error
+		if name == layout.Name {
+			b, contentType, err = encodeThumbnail(img)
+		} else {
+			b, err = encodeRendition(img)
+		}
+		if err != nil {
+			return fmt.Errorf("encoding %s rendition: %w", name, err)
+		}
+		if err := saveRendition(writer, base, photo, name, b, contentType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveRendition saves one encoded rendition to the Drive rendition folder as <base>_<rendition>, where base is the
+// start of the filename for the item (see filenameConvention.Format), recording the photo it was rendered from in the
+// file properties. The YouTube thumbnail can be a JPEG one time and a PNG the next (see encodeThumbnail), so the file
+// with the other extension is trashed.
+func saveRendition(writer *driveFolderWriter, base string, photo *mediaFile, name string, b []byte, contentType string) error {
+	ext, other := ".jpg", ".png"
+	if contentType == "image/png" {
+		ext, other = ".png", ".jpg"
+	}
+	fname := base + "_" + name + ext
+	properties := map[string]string{"source": photo.Id, "sourceName": photo.Name, "rendition": name}
+	if err := writer.Write(fname, contentType, b, properties); err != nil {
+		return fmt.Errorf("saving %s rendition: %w", name, err)
+	}
+	if err := writer.Delete(base + "_" + name + other); err != nil {
+		return fmt.Errorf("removing old %s rendition: %w", name, err)
+	}
+	return nil
+}
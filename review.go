@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const ReviewOutputDir = "./review"
+
+// YouTube shows thumbnails at roughly these widths: the home page grid, search results and the up next sidebar.
+var reviewDisplayWidths = []int{480, 360, 168}
+
+// The contact sheet is a grid of small thumbnails with the key and title underneath. Thumbnails with warnings get a
+// red border.
+const reviewContactColumns = 6
+const reviewContactWidth = 320
+const reviewContactLabel = 36
+const reviewContactGap = 8
+
+// reviewEntry is a thumbnail in the review gallery.
+type reviewEntry struct {
+	Key      int
+	Type     string
+	Title    string
+	Photo    string
+	Image    string // file name of the rendered thumbnail, relative to the gallery
+	Size     int    // bytes, as it would be uploaded
+	Adjust   string // photo adjustments applied
+	Warnings []string
+	rendered image.Image
+}
+
+// reviewCommand renders the YouTube thumbnail for every item in the expedition, exactly as it would be uploaded, and
+// writes an HTML gallery and a contact sheet to review/<expedition>, so a batch can be signed off at once.
+func reviewCommand(ctx context.Context, args []string) error {
+	expedition := "ght"
+	if len(args) > 0 {
+		expedition = args[0]
+	}
+
+	media, err := getMediaSource(ctx, expedition)
+	if err != nil {
+		return fmt.Errorf("getting media source: %w", err)
+	}
+
+	layout, items, err := getRenditionSources(media, expedition)
+	if err != nil {
+		return err
+	}
+
+	state, err := loadState()
+	if err != nil {
+		return fmt.Errorf("loading state: %w", err)
+	}
+
+	dir := filepath.Join(ReviewOutputDir, expedition)
+	if err := os.MkdirAll(filepath.Join(dir, "thumbnails"), 0777); err != nil {
+		return fmt.Errorf("creating review folder: %w", err)
+	}
+
+	var entries []*reviewEntry
+	for _, item := range items {
+		if item.PhotoFile == nil && !item.HasVideo {
+			continue
+		}
+		entry := &reviewEntry{Key: item.Key, Type: item.Type, Title: item.Title, Photo: item.Photo}
+		entries = append(entries, entry)
+
+		if item.PhotoFile == nil {
+			entry.Warnings = append(entry.Warnings, "no photo in the thumbnails folder")
+			continue
+		}
+
+		fmt.Println("Downloading thumbnail", item.Photo)
+		download, err := media.Open(item.PhotoFile)
+		if err != nil {
+			return fmt.Errorf("opening thumbnail: %w", err)
+		}
+		img, err := decodePhoto(download)
+		download.Close()
+		if err != nil {
+			entry.Warnings = append(entry.Warnings, fmt.Sprintf("can't decode photo: %v", err))
+			continue
+		}
+		if size := img.Bounds().Size(); size.X < layout.Width || size.Y < layout.Height {
+			entry.Warnings = append(entry.Warnings, fmt.Sprintf("photo is %dx%d, smaller than the %dx%d thumbnail", size.X, size.Y, layout.Width, layout.Height))
+		}
+		entry.Warnings = append(entry.Warnings, reviewLayerWarnings(layout, item.Item, item.Type)...)
+		if adj, err := parseAdjustments(item.Adjust, layout.adjust); err == nil {
+			entry.Adjust = adj.String()
+		}
+
+		// the review doesn't change the state, so a new automatic crop is only previewed
+		crop := state.Crops[item.Filename]
+		rendered, err := renderThumbnail(layout, item.Item, item.Type, item.Crop, item.Adjust, img, &crop)
+		if err != nil {
+			entry.Warnings = append(entry.Warnings, fmt.Sprintf("can't render: %v", err))
+			continue
+		}
+		entry.rendered = rendered
+
+		b, contentType, err := encodeThumbnail(rendered)
+		if err != nil {
+			entry.Warnings = append(entry.Warnings, err.Error())
+			continue
+		}
+		ext := ".jpg"
+		if contentType == "image/png" {
+			ext = ".png"
+		}
+		entry.Image = "thumbnails/" + strings.TrimSuffix(item.Photo, filepath.Ext(item.Photo)) + ext
+		entry.Size = len(b)
+		if err := ioutil.WriteFile(filepath.Join(dir, entry.Image), b, 0666); err != nil {
+			return fmt.Errorf("writing thumbnail: %w", err)
+		}
+	}
+
+	if err := writeReviewGallery(filepath.Join(dir, "index.html"), expedition, entries); err != nil {
+		return err
+	}
+	if err := writeContactSheet(filepath.Join(dir, "contact.jpg"), layout, entries); err != nil {
+		return err
+	}
+
+	var warned int
+	for _, entry := range entries {
+		if len(entry.Warnings) > 0 {
+			warned++
+		}
+	}
+	fmt.Printf("Reviewed %d thumbnails, %d with warnings, in %s\n", len(entries), warned, dir)
+	return nil
+}
+
+// reviewLayerWarnings lists the layers in the layout and its renditions where the text doesn't fit.
+func reviewLayerWarnings(layout *thumbnailLayout, item interface{}, typ string) []string {
+	var warnings []string
+	for _, l := range append([]*thumbnailLayout{layout}, layout.Renditions...) {
+		bounds := image.Rect(0, 0, l.Width, l.Height)
+		for i, layer := range l.Layers {
+			drawn, err := layer.drawnFor(item, typ)
+			if err == nil && drawn {
+				_, err = placeThumbnailLayer(l, layer, item, bounds)
+			}
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("%s layer %d: %v", l.Name, i, err))
+			}
+		}
+	}
+	return warnings
+}
+
+var reviewHtmlTemplate = template.Must(template.New("main").Funcs(template.FuncMap{
+	"height": func(width int) int { return width * 9 / 16 },
+	"kb":     func(b int) int { return b / 1024 },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Thumbnail review: {{ .Expedition }}</title>
+<style>
+body { font-family: sans-serif; margin: 2rem; }
+.entry { display: flex; gap: 1rem; align-items: flex-start; padding: 1rem 0; border-bottom: 1px solid #ddd; }
+.entry img { background: #eee; }
+.details { max-width: 24rem; }
+.title { font-weight: bold; }
+.meta { color: #888; }
+.warnings { color: #c00; }
+.missing { display: inline-block; background: #eee; }
+</style>
+</head>
+<body>
+<h1>Thumbnail review: {{ .Expedition }}</h1>
+<p>{{ len .Entries }} thumbnails. <a href="contact.jpg">Contact sheet</a>.</p>
+{{ range .Entries }}
+<div class="entry">
+<div class="details">
+<div class="title">{{ .Title }}</div>
+<div class="meta">{{ .Type }} {{ .Key }}{{ if .Photo }} &middot; {{ .Photo }}{{ end }}{{ if .Size }} &middot; {{ kb .Size }} KB{{ end }}</div>
+{{ if .Adjust }}<div class="meta">Adjustments: {{ .Adjust }}</div>{{ end }}
+{{ if .Warnings }}<ul class="warnings">{{ range .Warnings }}<li>{{ . }}</li>{{ end }}</ul>{{ end }}
+</div>
+{{ $entry := . }}{{ range $.Widths }}{{ if $entry.Image }}<a href="{{ $entry.Image }}"><img src="{{ $entry.Image }}" width="{{ . }}" height="{{ height . }}"></a>{{ else }}<span class="missing" style="width: {{ . }}px; height: {{ height . }}px"></span>{{ end }}
+{{ end }}
+</div>
+{{ end }}
+</body>
+</html>
+`))
+
+func writeReviewGallery(fname, expedition string, entries []*reviewEntry) error {
+	f, err := os.Create(fname)
+	if err != nil {
+		return fmt.Errorf("creating %q: %w", fname, err)
+	}
+	defer f.Close()
+	data := struct {
+		Expedition string
+		Entries    []*reviewEntry
+		Widths     []int
+	}{expedition, entries, reviewDisplayWidths}
+	if err := reviewHtmlTemplate.Execute(f, data); err != nil {
+		return fmt.Errorf("executing review template: %w", err)
+	}
+	fmt.Println("Wrote", fname)
+	return nil
+}
+
+// writeContactSheet draws every thumbnail in a grid, with the key and title underneath.
+func writeContactSheet(fname string, layout *thumbnailLayout, entries []*reviewEntry) error {
+	cellW := reviewContactWidth
+	cellH := reviewContactWidth * layout.Height / layout.Width
+	rows := (len(entries) + reviewContactColumns - 1) / reviewContactColumns
+	sheet := image.NewNRGBA(image.Rect(0, 0,
+		reviewContactColumns*(cellW+reviewContactGap)+reviewContactGap,
+		rows*(cellH+reviewContactLabel+reviewContactGap)+reviewContactGap,
+	))
+	draw.Draw(sheet, sheet.Bounds(), image.White, image.Point{}, draw.Src)
+
+	red := image.NewUniform(color.NRGBA{R: 204, A: 255})
+	grey := image.NewUniform(color.NRGBA{R: 238, G: 238, B: 238, A: 255})
+	for i, entry := range entries {
+		x := reviewContactGap + (i%reviewContactColumns)*(cellW+reviewContactGap)
+		y := reviewContactGap + (i/reviewContactColumns)*(cellH+reviewContactLabel+reviewContactGap)
+		cell := image.Rect(x, y, x+cellW, y+cellH)
+		if len(entry.Warnings) > 0 {
+			draw.Draw(sheet, cell.Inset(-3), red, image.Point{}, draw.Src)
+		}
+		if entry.rendered != nil {
+			draw.Draw(sheet, cell, imaging.Resize(entry.rendered, cellW, cellH, imaging.Lanczos), image.Point{}, draw.Src)
+		} else {
+			draw.Draw(sheet, cell, grey, image.Point{}, draw.Src)
+		}
+
+		d := &font.Drawer{Dst: sheet, Src: image.Black, Face: basicfont.Face7x13}
+		label := fmt.Sprintf("%s %d", entry.Type, entry.Key)
+		if entry.Photo != "" {
+			label += " - " + entry.Photo
+		}
+		switch len(entry.Warnings) {
+		case 0:
+		case 1:
+			label += " - 1 warning"
+		default:
+			label += fmt.Sprintf(" - %d warnings", len(entry.Warnings))
+		}
+		for j, line := range []string{label, entry.Title} {
+			d.Dot = fixed.P(x, y+cellH+16+j*14)
+			d.DrawString(truncateLabel(d, line, cellW))
+		}
+	}
+
+	f, err := os.Create(fname)
+	if err != nil {
+		return fmt.Errorf("creating %q: %w", fname, err)
+	}
+	if err := jpeg.Encode(f, sheet, &jpeg.Options{Quality: RenditionQuality}); err != nil {
+		f.Close()
+		return fmt.Errorf("encoding contact sheet: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing contact sheet: %w", err)
+	}
+	fmt.Println("Wrote", fname)
+	return nil
+}
+
+// truncateLabel shortens the text with an ellipsis until it fits in width.
+func truncateLabel(d *font.Drawer, s string, width int) string {
+	if d.MeasureString(s).Round() <= width {
+		return s
+	}
+	r := []rune(s)
+	for len(r) > 0 && d.MeasureString(string(r)+"...").Round() > width {
+		r = r[:len(r)-1]
+	}
+	return string(r) + "..."
+}
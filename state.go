@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+const StateFilename = "./state.json"
+
+// State is persisted between runs so we remember things YouTube is slow to tell us (e.g. newly inserted videos
+// don't show up in search results for a while).
+type State struct {
+	// Videos maps the meta data filename (see GetFilename) to the YouTube video ID.
+	Videos map[string]string
+	// Playlists maps the GHT section name to the YouTube playlist ID.
+	Playlists map[string]string
+	// Captions maps the YouTube video ID and language (e.g. "dQw4w9WgXcQ/en") to the uploaded caption track.
+	Captions map[string]CaptionState
+	// Thumbnails maps the YouTube video ID to the hash of the uploaded thumbnail bytes.
+	Thumbnails map[string]string
+	// Crops maps the meta data filename to the thumbnail crop box chosen by an automatic crop strategy.
+	Crops map[string]CropState
+}
+
+func loadState() (*State, error) {
+	s := &State{}
+	raw, err := ioutil.ReadFile(StateFilename)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading state file: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(raw, s); err != nil {
+			return nil, fmt.Errorf("parsing state file: %w", err)
+		}
+	}
+	if s.Videos == nil {
+		s.Videos = map[string]string{}
+	}
+	if s.Playlists == nil {
+		s.Playlists = map[string]string{}
+	}
+	if s.Captions == nil {
+		s.Captions = map[string]CaptionState{}
+	}
+	if s.Thumbnails == nil {
+		s.Thumbnails = map[string]string{}
+	}
+	if s.Crops == nil {
+		s.Crops = map[string]CropState{}
+	}
+	return s, nil
+}
+
+func (s *State) Save() error {
+	raw, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return fmt.Errorf("encoding state: %w", err)
+	}
+	if err := ioutil.WriteFile(StateFilename, raw, 0666); err != nil {
+		return fmt.Errorf("writing state file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// statusChange is a planned change to the privacy status and PublishAt of a video.
+type statusChange struct {
+	Episode *managedEpisode
+	Status  *youtube.VideoStatus
+	Refused string // why the change is refused without --force
+}
+
+// newVideoStatus is the status for a new upload: private, and days are scheduled to go public at their live time.
+func newVideoStatus(typ string, liveTime time.Time) *youtube.VideoStatus {
+	status := &youtube.VideoStatus{PrivacyStatus: "private"}
+	if typ == "day" {
+		status.PublishAt = formatPublishAt(liveTime)
+	}
+	return status
+}
+
+func formatPublishAt(t time.Time) string {
+	return strings.TrimSuffix(t.UTC().Format(time.RFC3339), "Z") + ".0Z"
+}
+
+// statusCommand lists or changes the privacy status of the videos:
+//
+//	status list [ght|ant] [selector]
+//	status schedule|public|unlist|private [ght|ant] [selector] [--force] [-n]
+//	status reschedule ght|ant [selector] <time> [--force] [-n]
+//
+// The selector is a key (12), a key range (10-20), a GHT section name or "trailer". Schedule sets PublishAt to the
+// live time in the data, and reschedule moves the selected videos of one expedition so the first goes live at the
+// given time (keeping the gaps between them). Videos that are already public or past their PublishAt are refused
+// unless --force is given, because changing them can break the premiere.
+func statusCommand(ctx context.Context, args []string) error {
+	var force, dryRun bool
+	var positional []string
+	for _, arg := range args {
+		switch arg {
+		case "--force":
+			force = true
+		case "-n", "--dry-run":
+			dryRun = true
+		default:
+			positional = append(positional, arg)
+		}
+	}
+	if len(positional) == 0 {
+		return fmt.Errorf("usage: status list|schedule|reschedule|public|unlist|private [ght|ant] [selector] [time]")
+	}
+	action := positional[0]
+	positional = positional[1:]
+
+	var expedition, selector, startArg string
+	for _, arg := range positional {
+		switch {
+		case arg == "ght" || arg == "ant":
+			expedition = arg
+		case action == "reschedule" && startArg == "" && isStatusTime(arg):
+			startArg = arg
+		case selector == "":
+			selector = arg
+		default:
+			return fmt.Errorf("unexpected argument %q", arg)
+		}
+	}
+
+	switch action {
+	case "list", "schedule", "public", "unlist", "private":
+	case "reschedule":
+		if startArg == "" {
+			return fmt.Errorf("reschedule needs a time, e.g. 2020-03-01 or 2020-03-01T21:00:00Z")
+		}
+		if expedition == "" {
+			// the gaps between the two expeditions' videos mean nothing, so don't move them together
+			return fmt.Errorf("reschedule needs an expedition, ght or ant")
+		}
+	default:
+		return fmt.Errorf("unknown status action %q", action)
+	}
+
+	youtubeService, err := getYoutubeService(ctx)
+	if err != nil {
+		return fmt.Errorf("getting youtube service: %w", err)
+	}
+
+	all, err := getManagedEpisodes(youtubeService)
+	if err != nil {
+		return fmt.Errorf("getting episodes: %w", err)
+	}
+
+	episodes, err := selectEpisodes(all, expedition, selector)
+	if err != nil {
+		return err
+	}
+	if len(episodes) == 0 {
+		return fmt.Errorf("no videos selected")
+	}
+
+	if action == "list" {
+		return writeStatusTable(os.Stdout, episodes, time.Now())
+	}
+
+	var start time.Time
+	if action == "reschedule" {
+		start, err = parseStatusTime(startArg, firstLiveTime(episodes))
+		if err != nil {
+			return err
+		}
+	}
+
+	changes, skipped, err := planStatusChanges(episodes, action, start, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, e := range skipped {
+		fmt.Printf("Skipping %s %s %d, only days can be scheduled\n", e.Expedition, e.Type, e.Key)
+	}
+
+	var refused []string
+	for _, change := range changes {
+		if change.Refused != "" {
+			refused = append(refused, fmt.Sprintf("%s %d (%s)", change.Episode.Expedition, change.Episode.Key, change.Refused))
+		}
+	}
+	if len(refused) > 0 && !force {
+		return fmt.Errorf("refusing to change %d videos, use --force to override: %s", len(refused), strings.Join(refused, ", "))
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("No status changes needed")
+		return nil
+	}
+
+	for _, change := range changes {
+		description := change.Status.PrivacyStatus
+		if change.Status.PublishAt != "" {
+			description += ", publish at " + change.Status.PublishAt
+		}
+		if dryRun {
+			fmt.Printf("Would update %s %d to %s: %q\n", change.Episode.Expedition, change.Episode.Key, description, change.Episode.Title)
+			continue
+		}
+		fmt.Printf("Updating %s %d to %s: %q\n", change.Episode.Expedition, change.Episode.Key, description, change.Episode.Title)
+		video := &youtube.Video{
+			Id:     change.Episode.VideoId,
+			Status: change.Status,
+		}
+		if _, err := youtubeService.Videos.Update([]string{"status"}, video).Do(); err != nil {
+			return fmt.Errorf("updating video status: %w", err)
+		}
+	}
+	return nil
+}
+
+// selectEpisodes filters the episodes by expedition and selector (see statusCommand).
+func selectEpisodes(episodes []*managedEpisode, expedition, selector string) ([]*managedEpisode, error) {
+	match := func(e *managedEpisode) bool { return true }
+	switch {
+	case selector == "":
+	case selector == "trailer":
+		match = func(e *managedEpisode) bool { return e.Type == "trailer" }
+	case selector[0] >= '0' && selector[0] <= '9':
+		from, to := selector, selector
+		if i := strings.Index(selector, "-"); i > -1 {
+			from, to = selector[:i], selector[i+1:]
+		}
+		min, err := strconv.Atoi(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key range %q", selector)
+		}
+		max, err := strconv.Atoi(to)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key range %q", selector)
+		}
+		match = func(e *managedEpisode) bool { return e.Type == "day" && e.Key >= min && e.Key <= max }
+	default:
+		var found bool
+		for _, e := range episodes {
+			if strings.EqualFold(e.Section, selector) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no section named %q", selector)
+		}
+		match = func(e *managedEpisode) bool { return strings.EqualFold(e.Section, selector) }
+	}
+
+	var selected []*managedEpisode
+	for _, e := range episodes {
+		if expedition != "" && e.Expedition != expedition {
+			continue
+		}
+		if match(e) {
+			selected = append(selected, e)
+		}
+	}
+	return selected, nil
+}
+
+// planStatusChanges works out the new status for each episode. Videos already in the requested state are left out,
+// so running the same command twice doesn't touch anything. Schedule and reschedule skip episodes that aren't days,
+// and return them so they can be reported.
+func planStatusChanges(episodes []*managedEpisode, action string, start time.Time, now time.Time) ([]*statusChange, []*managedEpisode, error) {
+	var changes []*statusChange
+	var skipped []*managedEpisode
+	first := firstLiveTime(episodes)
+	for _, e := range episodes {
+		current := e.Video.Status
+		if current == nil {
+			current = &youtube.VideoStatus{}
+		}
+
+		// copy the current status, so the fields we don't manage are left as they are
+		status := *current
+		var publishAt time.Time
+		switch action {
+		case "schedule", "reschedule":
+			if e.Type != "day" {
+				skipped = append(skipped, e)
+				continue
+			}
+			publishAt = e.LiveTime
+			if action == "reschedule" {
+				publishAt = start.Add(e.LiveTime.Sub(first))
+			}
+			status.PrivacyStatus = "private"
+			status.PublishAt = formatPublishAt(publishAt)
+		case "public":
+			status.PrivacyStatus = "public"
+			status.PublishAt = ""
+		case "unlist":
+			status.PrivacyStatus = "unlisted"
+			status.PublishAt = ""
+		case "private":
+			status.PrivacyStatus = "private"
+			status.PublishAt = ""
+		}
+
+		if status.PrivacyStatus == current.PrivacyStatus && samePublishAt(status.PublishAt, current.PublishAt) {
+			continue
+		}
+
+		change := &statusChange{Episode: e, Status: &status}
+		switch {
+		case current.PrivacyStatus == "public":
+			change.Refused = "already public"
+		case current.PublishAt != "" && !publishAtAfter(current.PublishAt, now):
+			change.Refused = "past its PublishAt"
+		case !publishAt.IsZero() && !publishAt.After(now):
+			return nil, nil, fmt.Errorf("%s %d would be scheduled in the past (%s)", e.Expedition, e.Key, publishAt.Format(time.RFC3339))
+		}
+		changes = append(changes, change)
+	}
+	return changes, skipped, nil
+}
+
+// firstLiveTime is the earliest live time of the days, which reschedule moves to the given time.
+func firstLiveTime(episodes []*managedEpisode) time.Time {
+	var first time.Time
+	for _, e := range episodes {
+		if e.Type == "day" && (first.IsZero() || e.LiveTime.Before(first)) {
+			first = e.LiveTime
+		}
+	}
+	return first
+}
+
+func writeStatusTable(w io.Writer, episodes []*managedEpisode, now time.Time) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "EXPEDITION\tKEY\tTYPE\tPRIVACY\tPUBLISH AT\tLIVE TIME\tNOTE\tTITLE")
+	for _, e := range episodes {
+		status := e.Video.Status
+		if status == nil {
+			status = &youtube.VideoStatus{}
+		}
+		var note string
+		switch {
+		case status.PublishAt != "" && !publishAtAfter(status.PublishAt, now):
+			note = "past PublishAt"
+		case status.PublishAt != "" && e.Type == "day" && !samePublishAt(status.PublishAt, formatPublishAt(e.LiveTime)):
+			note = "differs from live time"
+		case status.PrivacyStatus == "private" && status.PublishAt == "":
+			note = "not scheduled"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.Expedition,
+			e.Key,
+			e.Type,
+			status.PrivacyStatus,
+			status.PublishAt,
+			e.LiveTime.Format(time.RFC3339),
+			note,
+			e.Title,
+		)
+	}
+	return tw.Flush()
+}
+
+// samePublishAt compares two PublishAt values as times, because the API doesn't return them in the format we send.
+func samePublishAt(a, b string) bool {
+	if a == "" || b == "" {
+		return a == b
+	}
+	ta, errA := time.Parse(time.RFC3339, a)
+	tb, errB := time.Parse(time.RFC3339, b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return ta.Equal(tb)
+}
+
+func publishAtAfter(publishAt string, now time.Time) bool {
+	t, err := time.Parse(time.RFC3339, publishAt)
+	if err != nil {
+		return false
+	}
+	return t.After(now)
+}
+
+func isStatusTime(s string) bool {
+	return len(s) >= 10 && s[4] == '-' && s[7] == '-'
+}
+
+// parseStatusTime parses a full RFC3339 time, or a date which goes live at the same time of day as the reference.
+func parseStatusTime(s string, reference time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, should be 2020-03-01 or 2020-03-01T21:00:00Z", s)
+	}
+	reference = reference.UTC()
+	return t.Add(time.Duration(reference.Hour())*time.Hour + time.Duration(reference.Minute())*time.Minute), nil
+}
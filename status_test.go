@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+var testStatusNow = time.Date(2020, 3, 10, 12, 0, 0, 0, time.UTC)
+
+// testEpisode is a GHT day (or the trailer for key 0) going live at 21:00 on that day of March 2020, with the given
+// privacy status and PublishAt.
+func testEpisode(key int, privacy, publishAt string) *managedEpisode {
+	e := &managedEpisode{
+		Expedition: "ght",
+		Key:        key,
+		Type:       "day",
+		LiveTime:   time.Date(2020, 3, key, 21, 0, 0, 0, time.UTC),
+		Video:      &youtube.Video{Status: &youtube.VideoStatus{PrivacyStatus: privacy, PublishAt: publishAt}},
+	}
+	if key == 0 {
+		e.Type, e.Key = "trailer", 1
+	}
+	return e
+}
+
+func describeChanges(changes []*statusChange) string {
+	var s []string
+	for _, c := range changes {
+		d := fmt.Sprintf("%d:%s", c.Episode.Key, c.Status.PrivacyStatus)
+		if c.Status.PublishAt != "" {
+			d += "@" + c.Status.PublishAt
+		}
+		if c.Refused != "" {
+			d += "!" + c.Refused
+		}
+		s = append(s, d)
+	}
+	return strings.Join(s, " ")
+}
+
+func TestPlanStatusChanges(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		episodes []*managedEpisode
+		action   string
+		start    time.Time
+		want     string
+		skipped  int
+		err      bool
+	}{
+		{
+			name:     "schedule",
+			episodes: []*managedEpisode{testEpisode(12, "private", ""), testEpisode(13, "private", "")},
+			action:   "schedule",
+			want:     "12:private@2020-03-12T21:00:00.0Z 13:private@2020-03-13T21:00:00.0Z",
+		},
+		{
+			name:     "already scheduled is a no-op",
+			episodes: []*managedEpisode{testEpisode(12, "private", "2020-03-12T21:00:00Z"), testEpisode(13, "private", "2020-03-13T21:00:00.000Z")},
+			action:   "schedule",
+			want:     "",
+		},
+		{
+			name:     "trailer skipped",
+			episodes: []*managedEpisode{testEpisode(0, "public", ""), testEpisode(12, "private", "")},
+			action:   "schedule",
+			want:     "12:private@2020-03-12T21:00:00.0Z",
+			skipped:  1,
+		},
+		{
+			name:     "public refused",
+			episodes: []*managedEpisode{testEpisode(2, "public", ""), testEpisode(12, "private", "")},
+			action:   "schedule",
+			want:     "2:private@2020-03-02T21:00:00.0Z!already public 12:private@2020-03-12T21:00:00.0Z",
+		},
+		{
+			name:     "past publish at refused",
+			episodes: []*managedEpisode{testEpisode(12, "private", "2020-03-09T21:00:00Z")},
+			action:   "schedule",
+			want:     "12:private@2020-03-12T21:00:00.0Z!past its PublishAt",
+		},
+		{
+			name:     "unscheduled in the past",
+			episodes: []*managedEpisode{testEpisode(2, "private", "")},
+			action:   "schedule",
+			err:      true,
+		},
+		{
+			name:     "public refused when unlisting",
+			episodes: []*managedEpisode{testEpisode(2, "public", ""), testEpisode(12, "private", "2020-03-12T21:00:00Z")},
+			action:   "unlist",
+			want:     "2:unlisted!already public 12:unlisted",
+		},
+		{
+			name:     "already public is a no-op",
+			episodes: []*managedEpisode{testEpisode(2, "public", "")},
+			action:   "public",
+			want:     "",
+		},
+		{
+			name:     "reschedule keeps the gaps",
+			episodes: []*managedEpisode{testEpisode(14, "private", ""), testEpisode(12, "private", ""), testEpisode(15, "private", "")},
+			action:   "reschedule",
+			start:    time.Date(2020, 4, 1, 18, 0, 0, 0, time.UTC),
+			want:     "14:private@2020-04-03T18:00:00.0Z 12:private@2020-04-01T18:00:00.0Z 15:private@2020-04-04T18:00:00.0Z",
+		},
+		{
+			name:     "reschedule ignores the trailer",
+			episodes: []*managedEpisode{testEpisode(0, "private", ""), testEpisode(12, "private", "")},
+			action:   "reschedule",
+			start:    time.Date(2020, 4, 1, 18, 0, 0, 0, time.UTC),
+			want:     "12:private@2020-04-01T18:00:00.0Z",
+			skipped:  1,
+		},
+		{
+			name:     "reschedule into the past",
+			episodes: []*managedEpisode{testEpisode(12, "private", "")},
+			action:   "reschedule",
+			start:    time.Date(2020, 3, 1, 18, 0, 0, 0, time.UTC),
+			err:      true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			changes, skipped, err := planStatusChanges(test.episodes, test.action, test.start, testStatusNow)
+			if test.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", describeChanges(changes))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := describeChanges(changes); got != test.want {
+				t.Errorf("got %q, expected %q", got, test.want)
+			}
+			if len(skipped) != test.skipped {
+				t.Errorf("skipped %d, expected %d", len(skipped), test.skipped)
+			}
+		})
+	}
+}
+
+func TestSelectEpisodes(t *testing.T) {
+	var episodes []*managedEpisode
+	for _, e := range []struct {
+		expedition, typ, section string
+		key                      int
+	}{
+		{"ght", "trailer", "", 1},
+		{"ght", "day", "Kanchenjunga", 1},
+		{"ght", "day", "Kanchenjunga", 2},
+		{"ght", "day", "Makalu", 3},
+		{"ght", "day", "Makalu", 4},
+		{"ant", "day", "", 1},
+		{"ant", "day", "", 2},
+	} {
+		episodes = append(episodes, &managedEpisode{Expedition: e.expedition, Type: e.typ, Section: e.section, Key: e.key})
+	}
+	describe := func(selected []*managedEpisode) string {
+		var s []string
+		for _, e := range selected {
+			s = append(s, fmt.Sprintf("%s-%s-%d", e.Expedition, e.Type, e.Key))
+		}
+		return strings.Join(s, " ")
+	}
+	for _, test := range []struct {
+		expedition, selector string
+		want                 string
+		err                  bool
+	}{
+		{expedition: "", selector: "", want: "ght-trailer-1 ght-day-1 ght-day-2 ght-day-3 ght-day-4 ant-day-1 ant-day-2"},
+		{expedition: "ant", selector: "", want: "ant-day-1 ant-day-2"},
+		{expedition: "ght", selector: "2", want: "ght-day-2"},
+		{expedition: "ght", selector: "2-3", want: "ght-day-2 ght-day-3"},
+		{expedition: "", selector: "1-2", want: "ght-day-1 ght-day-2 ant-day-1 ant-day-2"},
+		{expedition: "ght", selector: "trailer", want: "ght-trailer-1"},
+		{expedition: "ght", selector: "makalu", want: "ght-day-3 ght-day-4"},
+		{expedition: "ant", selector: "Makalu", want: ""},
+		{expedition: "ght", selector: "Everest", err: true},
+		{expedition: "ght", selector: "2-x", err: true},
+	} {
+		selected, err := selectEpisodes(episodes, test.expedition, test.selector)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s %q: expected an error", test.expedition, test.selector)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %q: %v", test.expedition, test.selector, err)
+			continue
+		}
+		if got := describe(selected); got != test.want {
+			t.Errorf("%s %q: got %q, expected %q", test.expedition, test.selector, got, test.want)
+		}
+	}
+}
+
+func TestSamePublishAt(t *testing.T) {
+	for _, test := range []struct {
+		a, b string
+		want bool
+	}{
+		{"", "", true},
+		{"2020-03-12T21:00:00.0Z", "", false},
+		{"2020-03-12T21:00:00.0Z", "2020-03-12T21:00:00Z", true},
+		{"2020-03-12T21:00:00.0Z", "2020-03-12T21:00:00.000Z", true},
+		{"2020-03-12T21:00:00Z", "2020-03-12T22:00:00+01:00", true},
+		{"2020-03-12T21:00:00Z", "2020-03-12T21:00:01Z", false},
+		{"soon", "soon", true},
+		{"soon", "2020-03-12T21:00:00Z", false},
+	} {
+		if got := samePublishAt(test.a, test.b); got != test.want {
+			t.Errorf("samePublishAt(%q, %q) is %v, expected %v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestParseStatusTime(t *testing.T) {
+	reference := time.Date(2020, 3, 1, 21, 30, 0, 0, time.UTC)
+	for _, test := range []struct {
+		s    string
+		want time.Time
+		err  bool
+	}{
+		{s: "2020-04-01T18:00:00Z", want: time.Date(2020, 4, 1, 18, 0, 0, 0, time.UTC)},
+		{s: "2020-04-01T18:00:00+02:00", want: time.Date(2020, 4, 1, 16, 0, 0, 0, time.UTC)},
+		{s: "2020-04-01", want: time.Date(2020, 4, 1, 21, 30, 0, 0, time.UTC)},
+		{s: "2020-04-01T18:00", err: true},
+		{s: "2020-13-01", err: true},
+	} {
+		got, err := parseStatusTime(test.s, reference)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %v", test.s, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.s, err)
+			continue
+		}
+		if !got.Equal(test.want) {
+			t.Errorf("%q: got %v, expected %v", test.s, got, test.want)
+		}
+	}
+}
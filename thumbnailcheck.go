@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"math"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/youtube/v3"
+)
+
+// YouTube rejects thumbnails over 2 MB, and recommends at least 640 pixels wide in 16:9.
+const ThumbnailMaxBytes = 2 * 1024 * 1024
+const ThumbnailMinWidth = 640
+
+// JPEG qualities to try in order, until the thumbnail fits. If none fit we try PNG.
+var thumbnailQualities = []int{92, 85, 75, 65, 55}
+
+// encodeThumbnail checks the dimensions of the thumbnail, and encodes it at the highest quality that fits in the
+// size limit. It returns the bytes and the content type.
+func encodeThumbnail(img image.Image) ([]byte, string, error) {
+	size := img.Bounds().Size()
+	if size.X < ThumbnailMinWidth {
+		return nil, "", fmt.Errorf("thumbnail is %dx%d, should be at least %d wide", size.X, size.Y, ThumbnailMinWidth)
+	}
+	aspect := 16.0 / 9.0
+	if math.Abs(float64(size.X)/float64(size.Y)-aspect) > 0.01 {
+		return nil, "", fmt.Errorf("thumbnail is %dx%d, should have an aspect ratio of %.2f", size.X, size.Y, aspect)
+	}
+
+	var smallest int
+	for _, quality := range thumbnailQualities {
+		buf := &bytes.Buffer{}
+		if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, "", fmt.Errorf("encoding jpeg: %w", err)
+		}
+		if buf.Len() <= ThumbnailMaxBytes {
+			return buf.Bytes(), "image/jpeg", nil
+		}
+		smallest = buf.Len()
+	}
+
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, img); err != nil {
+		return nil, "", fmt.Errorf("encoding png: %w", err)
+	}
+	if buf.Len() <= ThumbnailMaxBytes {
+		return buf.Bytes(), "image/png", nil
+	}
+
+	return nil, "", fmt.Errorf("thumbnail is too big: %d bytes at the lowest jpeg quality, %d bytes as png, limit is %d", smallest, buf.Len(), ThumbnailMaxBytes)
+}
+
+// setThumbnail uploads the thumbnail encoded by encodeThumbnail, unless exactly the same bytes were uploaded to the
+// video last time. It returns true if the thumbnail was uploaded.
+func setThumbnail(srv *youtube.Service, state *State, videoId string, b []byte, contentType string) (bool, error) {
+	sum := sha256.Sum256(b)
+	hash := hex.EncodeToString(sum[:])
+	if state.Thumbnails[videoId] == hash {
+		fmt.Println("Thumbnail unchanged")
+		return false, nil
+	}
+
+	fmt.Printf("Uploading thumbnail (%d KB, %s)\n", len(b)/1024, contentType)
+	if _, err := srv.Thumbnails.Set(videoId).Media(bytes.NewReader(b), googleapi.ContentType(contentType)).Do(); err != nil {
+		return false, fmt.Errorf("youtube thumbnails set call: %w", err)
+	}
+
+	state.Thumbnails[videoId] = hash
+	if err := state.Save(); err != nil {
+		return false, fmt.Errorf("saving state: %w", err)
+	}
+	return true, nil
+}
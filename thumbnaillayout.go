@@ -0,0 +1,710 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/disintegration/imaging"
+	"github.com/dustin/go-humanize"
+	"github.com/edwvee/exiffix"
+	"github.com/golang/freetype"
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+)
+
+const GhtThumbnailLayout = "./ght_thumbnail.json"
+const AntThumbnailLayout = "./ant_thumbnail.json"
+
+// thumbnailLayout describes how a thumbnail is drawn: the photo is cropped to fill the canvas, then the layers are
+// drawn over it in order. The top level layout is the YouTube thumbnail, and Renditions are the other images made
+// from the same photo (square, story, etc.), which use the fonts and crop strategy of the top level unless they set
+// their own.
+type thumbnailLayout struct {
+	Name          string // names the rendition in output filenames, "youtube" if not set
+	Width, Height int
+	Crop          string               // crop strategy for items that don't set one (see cropAnchors)
+	Adjust        string               // photo adjustments (see parseAdjustments), which items can add to
+	Fonts         map[string]fontChain // font name to a TTF (see getFont), or a list to fall back through
+	Layers        []*thumbnailLayer
+	Renditions    []*thumbnailLayout
+
+	fonts  map[string][]*truetype.Font
+	adjust photoAdjustments
+}
+
+// thumbnailLayer is a line of text, with an optional box behind it.
+type thumbnailLayer struct {
+	Types  []string // item types the layer is drawn for, or all types if empty
+	When   string   // name of a bool field of the item (e.g. "Badges"): the layer is only drawn for items where it's true
+	Text   string   // template, executed with the item
+	Font   string   // name in Fonts
+	Size   float64
+	Colour string // #rrggbb or #rrggbbaa
+
+	// Anchor is the corner of the canvas X and Y are measured from: top-left (the default), top-right, bottom-left or
+	// bottom-right. X and Y give the start of the text baseline, or for right anchors the end.
+	Anchor string
+	X, Y   int
+
+	// The text is fitted into MaxWidth (by default the space from X to the edge of the canvas, less the far box padding):
+	// first by shrinking the font down to MinSize, then by wrapping at word boundaries onto up to MaxLines lines. Lines
+	// are LineSpacing times the font size apart (1.2 if not set). Further lines go down from the first baseline, or
+	// for bottom anchors up from the last one.
+	MaxWidth    int
+	MinSize     float64
+	MaxLines    int
+	LineSpacing float64
+
+	Box *thumbnailBox
+
+	// Progress draws a bar along the edge of the canvas, as well as any text.
+	Progress *thumbnailProgress
+
+	// Image draws a logo or watermark, as well as any text. X and Y are the margin from the Anchor corner of the canvas
+	// to the same corner of the image.
+	Image *thumbnailImage
+
+	template *template.Template
+	when     *template.Template
+}
+
+// thumbnailBox is drawn behind the text, padded around it. Top padding is measured up from the baseline and bottom
+// padding down from it.
+type thumbnailBox struct {
+	Colour  string
+	Padding struct {
+		Left, Top, Right, Bottom int
+	}
+	Extend string // "left" or "right" stretches the box to that edge of the canvas
+}
+
+// thumbnailProgress is a bar across the full width of the canvas, filled from the left in proportion to Value out of
+// Total. Both are templates executed with the item, e.g. "{{ .Key }}" and "{{ .TotalDays }}".
+type thumbnailProgress struct {
+	Value, Total string
+	Height       int
+	Edge         string // "bottom" (the default) or "top"
+	Colour       string // the filled part
+	Track        string // the rest of the bar, not drawn if empty
+
+	value, total *template.Template
+}
+
+// thumbnailImage is a PNG with an alpha channel (SVGs need rasterizing first), scaled to Width (or Height, if Width
+// isn't set), keeping its aspect ratio. A relative File is in the layout file's folder.
+type thumbnailImage struct {
+	File          string
+	Width, Height int
+	Opacity       *float64 // 0 to 1, 1 if not set
+
+	image   *image.NRGBA
+	opacity float64
+}
+
+var whenRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*$`)
+
+// Functions available in layer templates, e.g. "{{ comma .HighPointM }} m".
+var layerTemplateFuncs = template.FuncMap{
+	"comma": func(n int) string { return humanize.Comma(int64(n)) },
+}
+
+// Layouts are loaded once per file, rather than for every thumbnail rendered.
+var layoutCache = struct {
+	sync.Mutex
+	layouts map[string]*thumbnailLayout
+}{layouts: map[string]*thumbnailLayout{}}
+
+// loadThumbnailLayout returns the layout in the file, reading it the first time it's used.
+func loadThumbnailLayout(fname string) (*thumbnailLayout, error) {
+	layoutCache.Lock()
+	defer layoutCache.Unlock()
+	if layout, ok := layoutCache.layouts[fname]; ok {
+		return layout, nil
+	}
+	layout, err := readThumbnailLayout(fname)
+	if err != nil {
+		return nil, err
+	}
+	layoutCache.layouts[fname] = layout
+	return layout, nil
+}
+
+// readThumbnailLayout reads and checks a layout file, and loads the fonts it uses.
+func readThumbnailLayout(fname string) (*thumbnailLayout, error) {
+	b, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, fmt.Errorf("reading layout file: %w", err)
+	}
+	layout := &thumbnailLayout{}
+	if err := json.Unmarshal(b, layout); err != nil {
+		return nil, fmt.Errorf("parsing layout file %q: %w", fname, err)
+	}
+	if layout.Name == "" {
+		layout.Name = "youtube"
+	}
+	if err := layout.prepare(fname, filepath.Dir(fname)); err != nil {
+		return nil, err
+	}
+	names := map[string]bool{layout.Name: true}
+	for _, rendition := range layout.Renditions {
+		if rendition.Name == "" || names[rendition.Name] {
+			return nil, fmt.Errorf("rendition in %q needs a unique name", fname)
+		}
+		names[rendition.Name] = true
+		if rendition.Fonts == nil {
+			rendition.Fonts = layout.Fonts
+		}
+		if rendition.Crop == "" {
+			rendition.Crop = layout.Crop
+		}
+		if rendition.Adjust == "" {
+			rendition.Adjust = layout.Adjust
+		}
+		if len(rendition.Renditions) > 0 {
+			return nil, fmt.Errorf("rendition %q in %q can't have renditions", rendition.Name, fname)
+		}
+		if err := rendition.prepare(fmt.Sprintf("%s (%s)", fname, rendition.Name), filepath.Dir(fname)); err != nil {
+			return nil, err
+		}
+	}
+	return layout, nil
+}
+
+// prepare checks the layout, loads its fonts and images and parses the layer templates. Image files are relative to
+// dir, the layout file's folder.
+func (layout *thumbnailLayout) prepare(fname, dir string) error {
+	if layout.Width <= 0 || layout.Height <= 0 {
+		return fmt.Errorf("layout %q has no canvas size", fname)
+	}
+
+	if err := checkCropStrategy(layout.Crop); err != nil {
+		return fmt.Errorf("layout %q: %w", fname, err)
+	}
+
+	var err error
+	if layout.adjust, err = parseAdjustments(layout.Adjust, photoAdjustments{}); err != nil {
+		return fmt.Errorf("layout %q: %w", fname, err)
+	}
+
+	layout.fonts = map[string][]*truetype.Font{}
+	for name, chain := range layout.Fonts {
+		fonts, err := loadFontChain(chain)
+		if err != nil {
+			return fmt.Errorf("layout %q: %w", fname, err)
+		}
+		layout.fonts[name] = fonts
+	}
+
+	for i, layer := range layout.Layers {
+		if layer.Text != "" && layout.fonts[layer.Font] == nil {
+			return fmt.Errorf("layer %d in %q uses unknown font %q", i, fname, layer.Font)
+		}
+		if _, err := parseColour(layer.Colour); layer.Text != "" && err != nil {
+			return fmt.Errorf("layer %d in %q: %w", i, fname, err)
+		}
+		if layer.Box != nil {
+			if _, err := parseColour(layer.Box.Colour); err != nil {
+				return fmt.Errorf("layer %d box in %q: %w", i, fname, err)
+			}
+		}
+		switch layer.Anchor {
+		case "", "top-left", "top-right", "bottom-left", "bottom-right":
+		default:
+			return fmt.Errorf("layer %d in %q has unknown anchor %q", i, fname, layer.Anchor)
+		}
+		var err error
+		layer.template, err = template.New("layer").Funcs(layerTemplateFuncs).Parse(layer.Text)
+		if err != nil {
+			return fmt.Errorf("parsing text of layer %d in %q: %w", i, fname, err)
+		}
+		if layer.When != "" {
+			if !whenRegex.MatchString(layer.When) {
+				return fmt.Errorf("layer %d in %q: when should be the name of a field, not %q", i, fname, layer.When)
+			}
+			layer.when = template.Must(template.New("when").Parse("{{ ." + layer.When + " }}"))
+		}
+		if layer.Image != nil {
+			if err := layer.Image.prepare(dir); err != nil {
+				return fmt.Errorf("layer %d in %q: %w", i, fname, err)
+			}
+		}
+		if p := layer.Progress; p != nil {
+			if _, err := parseColour(p.Colour); err != nil {
+				return fmt.Errorf("layer %d progress in %q: %w", i, fname, err)
+			}
+			if _, err := parseColour(p.Track); p.Track != "" && err != nil {
+				return fmt.Errorf("layer %d progress in %q: %w", i, fname, err)
+			}
+			switch p.Edge {
+			case "", "top", "bottom":
+			default:
+				return fmt.Errorf("layer %d progress in %q has unknown edge %q", i, fname, p.Edge)
+			}
+			if p.value, err = template.New("value").Funcs(layerTemplateFuncs).Parse(p.Value); err != nil {
+				return fmt.Errorf("parsing progress value of layer %d in %q: %w", i, fname, err)
+			}
+			if p.total, err = template.New("total").Funcs(layerTemplateFuncs).Parse(p.Total); err != nil {
+				return fmt.Errorf("parsing progress total of layer %d in %q: %w", i, fname, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Layer images are decoded once, the first time a layout uses them.
+var layerImageCache = struct {
+	sync.Mutex
+	images map[string]image.Image
+}{images: map[string]image.Image{}}
+
+// prepare loads and scales the image.
+func (li *thumbnailImage) prepare(dir string) error {
+	li.opacity = 1
+	if li.Opacity != nil {
+		li.opacity = *li.Opacity
+	}
+	if li.opacity < 0 || li.opacity > 1 {
+		return fmt.Errorf("image opacity %g should be from 0 to 1", li.opacity)
+	}
+
+	fname := li.File
+	if !filepath.IsAbs(fname) {
+		fname = filepath.Join(dir, fname)
+	}
+
+	layerImageCache.Lock()
+	img, ok := layerImageCache.images[fname]
+	if !ok {
+		f, err := os.Open(fname)
+		if err != nil {
+			layerImageCache.Unlock()
+			return fmt.Errorf("opening image: %w", err)
+		}
+		img, _, err = image.Decode(f)
+		f.Close()
+		if err != nil {
+			layerImageCache.Unlock()
+			return fmt.Errorf("decoding image %q: %w", fname, err)
+		}
+		layerImageCache.images[fname] = img
+	}
+	layerImageCache.Unlock()
+
+	switch {
+	case li.Width > 0:
+		li.image = imaging.Resize(img, li.Width, 0, imaging.Lanczos)
+	case li.Height > 0:
+		li.image = imaging.Resize(img, 0, li.Height, imaging.Lanczos)
+	default:
+		li.image = imaging.Clone(img)
+	}
+	return nil
+}
+
+// decodePhoto reads a photo, rotating it according to the EXIF orientation.
+func decodePhoto(file io.Reader) (image.Image, error) {
+	imgIn, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading image: %w", err)
+	}
+
+	img, _, err := exiffix.Decode(bytes.NewReader(imgIn))
+	if err != nil {
+		return nil, fmt.Errorf("decoding image: %w", err)
+	}
+	return img, nil
+}
+
+// renderThumbnail crops and adjusts the photo and draws the layers for the item. The item's crop strategy overrides
+// the layout's (except "fit", which keeps the whole photo), its adjustments are applied over the layout's, and the
+// chosen crop is recorded in saved (if not nil).
+func renderThumbnail(layout *thumbnailLayout, item interface{}, typ, crop, adjust string, img image.Image, saved *CropState) (*image.NRGBA, error) {
+	if crop == "" || layout.Crop == "fit" {
+		crop = layout.Crop
+	}
+	rgba, err := cropThumbnail(img, crop, layout.Width, layout.Height, saved)
+	if err != nil {
+		return nil, err
+	}
+
+	adj, err := parseAdjustments(adjust, layout.adjust)
+	if err != nil {
+		return nil, err
+	}
+	rgba = adjustPhoto(rgba, adj)
+
+	for _, layer := range layout.Layers {
+		drawn, err := layer.drawnFor(item, typ)
+		if err != nil {
+			return nil, err
+		}
+		if !drawn {
+			continue
+		}
+		if err := drawThumbnailLayer(rgba, layout, layer, item); err != nil {
+			return nil, err
+		}
+	}
+
+	return rgba, nil
+}
+
+// drawnFor is true if the layer is drawn for the item: its type is one of Types, and its When field is true.
+func (layer *thumbnailLayer) drawnFor(item interface{}, typ string) (bool, error) {
+	if len(layer.Types) > 0 && !containsString(layer.Types, typ) {
+		return false, nil
+	}
+	if layer.when == nil {
+		return true, nil
+	}
+	buf := &bytes.Buffer{}
+	if err := layer.when.Execute(buf, item); err != nil {
+		return false, fmt.Errorf("checking %s: %w", layer.When, err)
+	}
+	switch buf.String() {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("%s should be true or false, not %q", layer.When, buf.String())
+}
+
+// placedText is a layer's text after fitting: the lines, the font size, and where each baseline starts.
+type placedText struct {
+	Lines  []string
+	Size   float64
+	Points []image.Point
+	Widths []int
+}
+
+// placeThumbnailLayer fits the layer's text for the item and works out where it goes on the canvas.
+func placeThumbnailLayer(layout *thumbnailLayout, layer *thumbnailLayer, item interface{}, bounds image.Rectangle) (*placedText, error) {
+	buf := &bytes.Buffer{}
+	if err := layer.template.Execute(buf, item); err != nil {
+		return nil, fmt.Errorf("executing layer template: %w", err)
+	}
+	text := buf.String()
+
+	// layers with no text for the item (e.g. "{{ .Section }}" for an item with no section) aren't drawn
+	if strings.TrimSpace(text) == "" {
+		return &placedText{}, nil
+	}
+
+	if missing := missingGlyphs(layout.fonts[layer.Font], text); len(missing) > 0 {
+		return nil, fmt.Errorf("text %q has characters no font has: %q", text, string(missing))
+	}
+
+	maxWidth := layer.MaxWidth
+	if maxWidth == 0 {
+		// the box padding on the far side must fit on the canvas too
+		maxWidth = bounds.Dx() - layer.X
+		if layer.Box != nil && strings.HasSuffix(layer.Anchor, "right") {
+			maxWidth -= layer.Box.Padding.Left
+		} else if layer.Box != nil {
+			maxWidth -= layer.Box.Padding.Right
+		}
+	}
+
+	lines, size, widths, ok := fitText(layout.fonts[layer.Font], text, maxWidth, layer.Size, layer.MinSize, layer.MaxLines)
+	if !ok {
+		return nil, fmt.Errorf("text %q doesn't fit in %d pixels", text, maxWidth)
+	}
+
+	spacing := layer.LineSpacing
+	if spacing == 0 {
+		spacing = 1.2
+	}
+	lineHeight := int(spacing*size + 0.5)
+
+	placed := &placedText{Lines: lines, Size: size, Widths: widths}
+	for i := range lines {
+		x, y := layer.X, layer.Y+i*lineHeight
+		if strings.HasSuffix(layer.Anchor, "right") {
+			x = bounds.Max.X - layer.X - widths[i]
+		}
+		if strings.HasPrefix(layer.Anchor, "bottom") {
+			y = bounds.Max.Y - layer.Y - (len(lines)-1-i)*lineHeight
+		}
+		placed.Points = append(placed.Points, image.Pt(x, y))
+	}
+	return placed, nil
+}
+
+// fitText finds the largest size from size down to minSize, and the fewest lines up to maxLines, where every line of
+// the text is at most maxWidth wide. Fewer lines are preferred over a larger size.
+func fitText(fonts []*truetype.Font, text string, maxWidth int, size, minSize float64, maxLines int) (lines []string, fitted float64, widths []int, ok bool) {
+	if minSize == 0 || minSize > size {
+		minSize = size
+	}
+	if maxLines == 0 {
+		maxLines = 1
+	}
+	for n := 1; n <= maxLines; n++ {
+		for s := size; ; s-- {
+			// a fractional minSize is still tried, even though the steps from size skip over it
+			if s < minSize {
+				s = minSize
+			}
+			face := newFallbackFace(fonts, s)
+			lines, widths, ok := wrapText(face, text, maxWidth)
+			if ok && len(lines) <= n {
+				return lines, s, widths, true
+			}
+			if s == minSize {
+				break
+			}
+		}
+	}
+	return nil, 0, nil, false
+}
+
+// wrapText breaks the text at spaces so each line fits in maxWidth, using as few lines as possible. It fails if a
+// single word is too wide.
+func wrapText(face font.Face, text string, maxWidth int) (lines []string, widths []int, ok bool) {
+	// text that fits is left exactly as it is (including any trailing space, which makes the box wider)
+	if width := font.MeasureString(face, text).Round(); width <= maxWidth {
+		return []string{text}, []int{width}, true
+	}
+	var line string
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if font.MeasureString(face, candidate).Round() <= maxWidth {
+			line = candidate
+			continue
+		}
+		if line == "" {
+			return nil, nil, false
+		}
+		lines = append(lines, line)
+		line = word
+		if font.MeasureString(face, line).Round() > maxWidth {
+			return nil, nil, false
+		}
+	}
+	lines = append(lines, line)
+	for _, l := range lines {
+		widths = append(widths, font.MeasureString(face, l).Round())
+	}
+	return lines, widths, true
+}
+
+// renderRenditions renders the layout and all its renditions from the same photo, by name. The automatic crops are
+// recorded in crops (if not nil), by name.
+func renderRenditions(layout *thumbnailLayout, item interface{}, typ, crop, adjust string, img image.Image, crops map[string]CropState) (map[string]*image.NRGBA, error) {
+	images := map[string]*image.NRGBA{}
+	for _, l := range append([]*thumbnailLayout{layout}, layout.Renditions...) {
+		var saved *CropState
+		if crops != nil {
+			c := crops[l.Name]
+			saved = &c
+		}
+		rgba, err := renderThumbnail(l, item, typ, crop, adjust, img, saved)
+		if err != nil {
+			return nil, fmt.Errorf("rendering %s: %w", l.Name, err)
+		}
+		if saved != nil && *saved != (CropState{}) {
+			crops[l.Name] = *saved
+		}
+		images[l.Name] = rgba
+	}
+	return images, nil
+}
+
+func drawThumbnailLayer(rgba *image.NRGBA, layout *thumbnailLayout, layer *thumbnailLayer, item interface{}) error {
+	bounds := rgba.Bounds()
+	if layer.Progress != nil {
+		if err := drawProgressBar(rgba, layer.Progress, item); err != nil {
+			return err
+		}
+	}
+	if layer.Image != nil {
+		drawLayerImage(rgba, layer)
+	}
+
+	placed, err := placeThumbnailLayer(layout, layer, item, bounds)
+	if err != nil {
+		return err
+	}
+	if len(placed.Lines) == 0 {
+		return nil
+	}
+
+	fg, _ := parseColour(layer.Colour)
+
+	c := freetype.NewContext()
+	c.SetDPI(72)
+	c.SetFontSize(placed.Size)
+	c.SetClip(bounds)
+	c.SetDst(rgba)
+	c.SetSrc(image.NewUniform(fg))
+	c.SetHinting(font.HintingNone) // font.HintingFull
+
+	if layer.Box != nil {
+		bg, _ := parseColour(layer.Box.Colour)
+		first, last := placed.Points[0], placed.Points[len(placed.Points)-1]
+		box := image.Rectangle{
+			Min: image.Point{X: bounds.Max.X, Y: first.Y - layer.Box.Padding.Top},
+			Max: image.Point{X: bounds.Min.X, Y: last.Y + layer.Box.Padding.Bottom},
+		}
+		for i, p := range placed.Points {
+			if p.X-layer.Box.Padding.Left < box.Min.X {
+				box.Min.X = p.X - layer.Box.Padding.Left
+			}
+			if p.X+placed.Widths[i]+layer.Box.Padding.Right > box.Max.X {
+				box.Max.X = p.X + placed.Widths[i] + layer.Box.Padding.Right
+			}
+		}
+		switch layer.Box.Extend {
+		case "left":
+			box.Min.X = bounds.Min.X
+		case "right":
+			box.Max.X = bounds.Max.X
+		}
+		draw.Draw(rgba, box, image.NewUniform(bg), image.Point{}, draw.Over)
+	}
+
+	for i, line := range placed.Lines {
+		pt := freetype.Pt(placed.Points[i].X, placed.Points[i].Y)
+		for _, run := range fontRuns(layout.fonts[layer.Font], line) {
+			c.SetFont(run.Font)
+			if pt, err = c.DrawString(run.Text, pt); err != nil {
+				return fmt.Errorf("drawing font: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// drawLayerImage draws the image in the anchor corner, at the layer's opacity.
+func drawLayerImage(rgba *image.NRGBA, layer *thumbnailLayer) {
+	bounds := rgba.Bounds()
+	size := layer.Image.image.Bounds().Size()
+	x, y := bounds.Min.X+layer.X, bounds.Min.Y+layer.Y
+	if strings.HasSuffix(layer.Anchor, "right") {
+		x = bounds.Max.X - layer.X - size.X
+	}
+	if strings.HasPrefix(layer.Anchor, "bottom") {
+		y = bounds.Max.Y - layer.Y - size.Y
+	}
+	mask := image.NewUniform(color.Alpha{A: uint8(math.Round(layer.Image.opacity * 255))})
+	draw.DrawMask(rgba, image.Rect(x, y, x+size.X, y+size.Y), layer.Image.image, image.Point{}, mask, image.Point{}, draw.Over)
+}
+
+// drawProgressBar draws the track and the filled part of the bar.
+func drawProgressBar(rgba *image.NRGBA, p *thumbnailProgress, item interface{}) error {
+	value, err := executeNumber(p.value, item)
+	if err != nil {
+		return fmt.Errorf("progress value: %w", err)
+	}
+	total, err := executeNumber(p.total, item)
+	if err != nil {
+		return fmt.Errorf("progress total: %w", err)
+	}
+	if total <= 0 {
+		return fmt.Errorf("progress total is %g", total)
+	}
+	fraction := math.Min(math.Max(value/total, 0), 1)
+
+	bounds := rgba.Bounds()
+	bar := image.Rect(bounds.Min.X, bounds.Max.Y-p.Height, bounds.Max.X, bounds.Max.Y)
+	if p.Edge == "top" {
+		bar = image.Rect(bounds.Min.X, bounds.Min.Y, bounds.Max.X, bounds.Min.Y+p.Height)
+	}
+	filled := bar
+	filled.Max.X = bar.Min.X + int(math.Round(fraction*float64(bar.Dx())))
+
+	if p.Track != "" {
+		track, _ := parseColour(p.Track)
+		rest := bar
+		rest.Min.X = filled.Max.X
+		draw.Draw(rgba, rest, image.NewUniform(track), image.Point{}, draw.Over)
+	}
+	fg, _ := parseColour(p.Colour)
+	draw.Draw(rgba, filled, image.NewUniform(fg), image.Point{}, draw.Over)
+	return nil
+}
+
+// executeNumber executes the template with the item, and parses the result as a number.
+func executeNumber(t *template.Template, item interface{}) (float64, error) {
+	buf := &bytes.Buffer{}
+	if err := t.Execute(buf, item); err != nil {
+		return 0, fmt.Errorf("executing template: %w", err)
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(buf.String()), 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing number: %w", err)
+	}
+	return n, nil
+}
+
+// thumbnailItem is an item to check the thumbnail text for.
+type thumbnailItem struct {
+	Key  int
+	Type string
+	Item interface{}
+}
+
+// checkThumbnailText fits the text of every layer in every rendition for each item, and lists the keys where it
+// doesn't fit. Renditions with the fit crop strategy depend on the photo, so they're checked at their maximum size.
+func checkThumbnailText(layout *thumbnailLayout, items []thumbnailItem) error {
+	var failed []string
+	for _, item := range items {
+		for _, l := range append([]*thumbnailLayout{layout}, layout.Renditions...) {
+			bounds := image.Rect(0, 0, l.Width, l.Height)
+			for _, layer := range l.Layers {
+				drawn, err := layer.drawnFor(item.Item, item.Type)
+				if err == nil && drawn {
+					_, err = placeThumbnailLayer(l, layer, item.Item, bounds)
+				}
+				if err != nil {
+					failed = append(failed, fmt.Sprintf("%s %d %s (%v)", item.Type, item.Key, l.Name, err))
+				}
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("thumbnail text doesn't fit for %d items: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// parseColour parses #rrggbb or #rrggbbaa.
+func parseColour(s string) (color.NRGBA, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "#"))
+	if err != nil || !strings.HasPrefix(s, "#") || (len(b) != 3 && len(b) != 4) {
+		return color.NRGBA{}, fmt.Errorf("invalid colour %q, should be #rrggbb or #rrggbbaa", s)
+	}
+	c := color.NRGBA{R: b[0], G: b[1], B: b[2], A: 255}
+	if len(b) == 4 {
+		c.A = b[3]
+	}
+	return c, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -1,277 +1,68 @@
 package main
 
 import (
-	"bytes"
 	"fmt"
 	"image"
-	"image/color"
-	"image/draw"
-	"image/jpeg"
-	"io"
-	"io/ioutil"
-	"os"
-	"path/filepath"
-	"strconv"
 
-	"github.com/disintegration/imaging"
-	"github.com/edwvee/exiffix"
-	"github.com/golang/freetype"
-	"github.com/golang/freetype/truetype"
-	"golang.org/x/image/font"
 	"golang.org/x/net/context"
 )
 
-const fontSize = 75
-const SQUARE = false
+func transformAntImage(layout *thumbnailLayout, item *AntVideoData, img image.Image, crop *CropState) (*image.NRGBA, error) {
+	return renderThumbnail(layout, item, item.Type, item.Crop, item.Adjust, img, crop)
+}
 
-func transformAntImage(item *AntVideoData, file io.Reader, preview bool) (io.Reader, error) {
-	imgIn, err := ioutil.ReadAll(file)
-	if err != nil {
-		return nil, fmt.Errorf("reading image: %w", err)
-	}
-	imgBuffer := bytes.NewReader(imgIn)
+func transformGhtImage(layout *thumbnailLayout, item *GhtVideoData, img image.Image, crop *CropState) (*image.NRGBA, error) {
+	return renderThumbnail(layout, item, item.Type, item.Crop, item.Adjust, img, crop)
+}
 
-	img, _, err := exiffix.Decode(imgBuffer)
+// checkAntThumbnails checks the thumbnail text fits for all the selected items, so we fail before uploading anything.
+func checkAntThumbnails(data []*AntVideoData) error {
+	layout, err := loadThumbnailLayout(AntThumbnailLayout)
 	if err != nil {
-		return nil, fmt.Errorf("decoding image: %w", err)
-	}
-
-	// 1280x720
-
-	height := 720
-	if SQUARE {
-		height = 1280
+		return err
 	}
-
-	var rgba *image.NRGBA
-	if preview {
-		rgba = imaging.Fit(img, 1920, 1920, imaging.Lanczos)
-	} else {
-		rgba = imaging.Fill(img, 1280, height, imaging.Center, imaging.Lanczos)
-	}
-
-	if !preview {
-
-		bold, err := getFont("./JosefinSans-Bold.ttf")
-		if err != nil {
-			return nil, err
-		}
-		regular, err := getFont("./JosefinSans-Regular.ttf")
-		if err != nil {
-			return nil, err
-		}
-
-		fg := image.White
-		c := freetype.NewContext()
-		c.SetDPI(72)
-		c.SetFont(bold)
-		c.SetFontSize(fontSize)
-		c.SetClip(rgba.Bounds())
-		c.SetDst(rgba)
-		c.SetSrc(fg)
-		c.SetHinting(font.HintingNone) // font.HintingFull
-
-		// Draw background
-		draw.Draw(
-			rgba,
-			image.Rectangle{
-				Min: image.Point{
-					X: 810,
-					Y: 90,
-				},
-				Max: image.Point{
-					X: rgba.Bounds().Max.X,
-					Y: 225,
-				},
-			},
-			image.NewUniform(color.NRGBA{0, 0, 0, 128}),
-			image.Point{},
-			draw.Over,
-		)
-		// Draw the text.
-		_, err = c.DrawString("Antarctica", freetype.Pt(850, 180))
-		if err != nil {
-			return nil, fmt.Errorf("drawing font: %w", err)
-		}
-
-		if item.Type == "day" {
-			c.SetFont(regular)
-
-			// calculate the size of the text by drawing it onto a blank image
-			c.SetDst(image.NewRGBA(image.Rect(0, 0, 1280, height)))
-			pos, err := c.DrawString(fmt.Sprintf("Day %d: %s", item.Key, item.Short), freetype.Pt(0, 0))
-			if err != nil {
-				return nil, fmt.Errorf("drawing font: %w", err)
-			}
-
-			c.SetDst(rgba)
-
-			draw.Draw(
-				rgba,
-				image.Rectangle{
-					Min: image.Point{
-						X: 0,
-						Y: height - 220,
-					},
-					Max: image.Point{
-						X: pos.X.Round() + 100,
-						Y: height - 85,
-					},
-				},
-				image.NewUniform(color.NRGBA{0, 0, 0, 128}),
-				image.Point{},
-				draw.Over,
-			)
-
-			_, err = c.DrawString(fmt.Sprintf("Day %d: %s", item.Key, item.Short), freetype.Pt(50, height-130))
-			if err != nil {
-				return nil, fmt.Errorf("drawing font: %w", err)
-			}
+	var items []thumbnailItem
+	for _, item := range data {
+		if isSelected(item.Type, item.Key) {
+			items = append(items, thumbnailItem{Key: item.Key, Type: item.Type, Item: item})
 		}
 	}
-
-	r, w := io.Pipe()
-
-	go func() {
-		err := jpeg.Encode(w, rgba, nil)
-		if err != nil {
-			w.CloseWithError(err)
-		}
-		w.Close()
-	}()
-
-	return r, nil
+	return checkThumbnailText(layout, items)
 }
 
-func transformGhtImage(item *GhtVideoData, file io.Reader, preview bool) (io.Reader, error) {
-	imgIn, err := ioutil.ReadAll(file)
-	if err != nil {
-		return nil, fmt.Errorf("reading image: %w", err)
-	}
-	imgBuffer := bytes.NewReader(imgIn)
-
-	img, _, err := exiffix.Decode(imgBuffer)
+// checkGhtThumbnails checks the thumbnail text fits for all the selected items, so we fail before uploading anything.
+func checkGhtThumbnails(data []*GhtVideoData) error {
+	layout, err := loadThumbnailLayout(GhtThumbnailLayout)
 	if err != nil {
-		return nil, fmt.Errorf("decoding image: %w", err)
+		return err
 	}
-
-	// 1280x720
-
-	height := 720
-	if SQUARE {
-		height = 1280
-	}
-
-	var rgba *image.NRGBA
-	if preview {
-		rgba = imaging.Fit(img, 1920, 1920, imaging.Lanczos)
-	} else {
-		rgba = imaging.Fill(img, 1280, height, imaging.Center, imaging.Lanczos)
-	}
-
-	if !preview {
-
-		bold, err := getFont("./JosefinSans-Bold.ttf")
-		if err != nil {
-			return nil, err
-		}
-		regular, err := getFont("./JosefinSans-Regular.ttf")
-		if err != nil {
-			return nil, err
-		}
-
-		fg := image.White
-		c := freetype.NewContext()
-		c.SetDPI(72)
-		c.SetFont(bold)
-		c.SetFontSize(fontSize)
-		c.SetClip(rgba.Bounds())
-		c.SetDst(rgba)
-		c.SetSrc(fg)
-		c.SetHinting(font.HintingNone) // font.HintingFull
-
-		// Draw background
-		draw.Draw(
-			rgba,
-			image.Rectangle{
-				Min: image.Point{
-					X: 280,
-					Y: 90,
-				},
-				Max: image.Point{
-					X: rgba.Bounds().Max.X,
-					Y: 225,
-				},
-			},
-			image.NewUniform(color.NRGBA{0, 0, 0, 128}),
-			image.Point{},
-			draw.Over,
-		)
-		// Draw the text.
-		_, err = c.DrawString("The Great Himalaya Trail", freetype.Pt(320, 180))
-		if err != nil {
-			return nil, fmt.Errorf("drawing font: %w", err)
-		}
-
-		if item.Type == "day" {
-			c.SetFont(regular)
-
-			// calculate the size of the text by drawing it onto a blank image
-			c.SetDst(image.NewRGBA(image.Rect(0, 0, 1280, height)))
-			pos, err := c.DrawString(fmt.Sprintf("Day %d: %s", item.Key, item.Short), freetype.Pt(0, 0))
-			if err != nil {
-				return nil, fmt.Errorf("drawing font: %w", err)
-			}
-
-			c.SetDst(rgba)
-
-			draw.Draw(
-				rgba,
-				image.Rectangle{
-					Min: image.Point{
-						X: 0,
-						Y: height - 220,
-					},
-					Max: image.Point{
-						X: pos.X.Round() + 100,
-						Y: height - 85,
-					},
-				},
-				image.NewUniform(color.NRGBA{0, 0, 0, 128}),
-				image.Point{},
-				draw.Over,
-			)
-
-			_, err = c.DrawString(fmt.Sprintf("Day %d: %s", item.Key, item.Short), freetype.Pt(50, height-130))
-			if err != nil {
-				return nil, fmt.Errorf("drawing font: %w", err)
-			}
+	var items []thumbnailItem
+	for _, item := range data {
+		if item.HasVideo && isSelected(item.Type, item.Key) {
+			items = append(items, thumbnailItem{Key: item.Key, Type: item.Type, Item: item})
 		}
 	}
-
-	r, w := io.Pipe()
-
-	go func() {
-		err := jpeg.Encode(w, rgba, nil)
-		if err != nil {
-			w.CloseWithError(err)
-		}
-		w.Close()
-	}()
-
-	return r, nil
+	return checkThumbnailText(layout, items)
 }
 
-func getFont(fname string) (*truetype.Font, error) {
-	fontBytes, err := ioutil.ReadFile(fname)
+// checkThumbnailsCommand checks the thumbnail text fits for every item in both expeditions.
+func checkThumbnailsCommand(ctx context.Context, args []string) error {
+	antData, err := getAntData()
 	if err != nil {
-		return nil, fmt.Errorf("reading font file: %w", err)
+		return fmt.Errorf("can't load ant days: %w", err)
 	}
-	fontParsed, err := freetype.ParseFont(fontBytes)
+	if err := checkAntThumbnails(antData); err != nil {
+		return fmt.Errorf("antarctica: %w", err)
+	}
+	ghtData, err := getGhtData()
 	if err != nil {
-		return nil, fmt.Errorf("parsing font file: %w", err)
+		return fmt.Errorf("can't load ght days: %w", err)
+	}
+	if err := checkGhtThumbnails(ghtData); err != nil {
+		return fmt.Errorf("ght: %w", err)
 	}
-	return fontParsed, nil
+	fmt.Println("All thumbnail text fits")
+	return nil
 }
 
 func previewThumbnails(ctx context.Context) error {
@@ -280,38 +71,42 @@ func previewThumbnails(ctx context.Context) error {
 		return fmt.Errorf("can't load days: %w", err)
 	}
 
-	files, err := ioutil.ReadDir(thumbnailTestingImportDir)
+	layout, err := loadThumbnailLayout(AntThumbnailLayout)
+	if err != nil {
+		return err
+	}
+
+	// the photos are directly in the import directory, rather than in a thumbnails subdirectory
+	media := &localSource{dir: thumbnailTestingImportDir}
+	files, err := media.List("")
 	if err != nil {
 		return fmt.Errorf("getting files in folder: %w", err)
 	}
 
+	// files that aren't thumbnails are skipped rather than failing
+	var named []*namedFile
 	for _, f := range files {
-		matches := filenameRegex.FindStringSubmatch(f.Name())
-		if len(matches) != 3 {
+		n, err := AntFilenames.Parse(f, ThumbnailMedia)
+		if err != nil {
 			continue
-		} else {
-			var itemType string
-			fileType := matches[1]
-			switch fileType {
-			case "A":
-				itemType = "day"
-			}
-			keyNumber, err := strconv.Atoi(matches[2])
-			if err != nil {
-				return fmt.Errorf("parsing day number from %q: %w", f.Name, err)
-			}
-			var item *AntVideoData
-			for _, itm := range data {
-				if itm.Expedition == "ant" && itm.Type == itemType && itm.Key == keyNumber {
-					item = itm
-					break
-				}
-			}
-			if item == nil {
-				return fmt.Errorf("no item for type %s and key %d for file %q", itemType, keyNumber, f.Name())
+		}
+		named = append(named, n)
+	}
+	named, superseded := newestVersions(named)
+	reportSuperseded(thumbnailTestingImportDir, superseded)
+
+	for _, f := range named {
+		var item *AntVideoData
+		for _, itm := range data {
+			if itm.Expedition == "ant" && itm.Type == f.Type && itm.Key == f.Key {
+				item = itm
+				break
 			}
-			item.ThumbnailTesting = f
 		}
+		if item == nil {
+			return fmt.Errorf("no item for type %s and key %d for file %q", f.Type, f.Key, f.Name)
+		}
+		item.ThumbnailTesting = f.mediaFile
 	}
 
 	for _, item := range data {
@@ -321,26 +116,25 @@ func previewThumbnails(ctx context.Context) error {
 		}
 
 		fmt.Println("Opening thumbnail", item.Key)
-		input, err := os.Open(filepath.Join(thumbnailTestingImportDir, item.ThumbnailTesting.Name()))
+		input, err := media.Open(item.ThumbnailTesting)
 		if err != nil {
 			return fmt.Errorf("opening thumbnail: %w", err)
 		}
 
-		f, err := transformAntImage(item, input, false)
+		img, err := decodePhoto(input)
 		if err != nil {
 			input.Close()
-			return fmt.Errorf("transforming thumbnail: %w", err)
+			return fmt.Errorf("decoding thumbnail: %w", err)
 		}
 		input.Close()
 
-		b, err := ioutil.ReadAll(f)
+		images, err := renderRenditions(layout, item, item.Type, item.Crop, item.Adjust, img, nil)
 		if err != nil {
-			return fmt.Errorf("reading thumbnail: %w", err)
+			return fmt.Errorf("rendering thumbnail: %w", err)
 		}
 
-		err = ioutil.WriteFile(filepath.Join(thumbnailTestingOutputDir, item.ThumbnailTesting.Name()), b, 0666)
-		if err != nil {
-			return fmt.Errorf("writing thumbnail: %w", err)
+		if err := writeRenditions(thumbnailTestingOutputDir, item.ThumbnailTesting.Name, images); err != nil {
+			return err
 		}
 		//return nil
 	}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+)
+
+var updateGoldens = flag.Bool("update", false, "regenerate the golden thumbnails in testdata/thumbnails/golden")
+
+// Pixels further apart than goldenPixelThreshold (redmean colour distance, 0 to 765) count as different, and a
+// thumbnail fails if more than goldenMaxDiffer of its pixels are different. This allows for rounding differences
+// between platforms, but not for text moving by a pixel.
+const goldenPixelThreshold = 24
+const goldenMaxDiffer = 0.0005
+
+// goldenRow is a fixture data row, rendered from a fixture photo.
+type goldenRow struct {
+	Name  string
+	Photo string
+	Ght   *GhtVideoData
+	Ant   *AntVideoData
+}
+
+// goldenFailure is a thumbnail that doesn't match its golden, for the report.
+type goldenFailure struct {
+	Name            string
+	Golden, Got     string
+	Diff            string
+	Differ, Largest float64
+}
+
+func TestGoldenThumbnails(t *testing.T) {
+	raw, err := ioutil.ReadFile("./testdata/thumbnails/rows.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []goldenRow
+	if err := json.Unmarshal(raw, &rows); err != nil {
+		t.Fatal(err)
+	}
+
+	report := filepath.Join(os.TempDir(), "thumbnail-golden-report")
+	var failures []*goldenFailure
+	for _, row := range rows {
+		row := row
+		t.Run(row.Name, func(t *testing.T) {
+			got := renderGoldenRow(t, row)
+			fname := filepath.Join("testdata", "thumbnails", "golden", row.Name+".png")
+			if *updateGoldens {
+				writeGoldenPNG(t, fname, got)
+				return
+			}
+			golden := readGoldenPNG(t, fname)
+			differ, largest, diff := compareGolden(golden, got)
+			if differ <= goldenMaxDiffer {
+				return
+			}
+			f := &goldenFailure{Name: row.Name, Differ: differ, Largest: largest}
+			f.Golden, _ = filepath.Abs(fname)
+			f.Got = filepath.Join(report, row.Name+"_got.png")
+			f.Diff = filepath.Join(report, row.Name+"_diff.png")
+			writeGoldenPNG(t, f.Got, got)
+			writeGoldenPNG(t, f.Diff, diff)
+			failures = append(failures, f)
+			t.Errorf("%.3f%% of pixels differ from %s (largest distance %.0f)", differ*100, fname, largest)
+		})
+	}
+
+	if len(failures) > 0 {
+		writeGoldenReport(t, filepath.Join(report, "index.html"), failures)
+		t.Logf("diff report in %s, run go test -run TestGoldenThumbnails -update to accept the changes", filepath.Join(report, "index.html"))
+	}
+}
+
+func mustLoadLayout(t *testing.T, fname string) *thumbnailLayout {
+	layout, err := loadThumbnailLayout(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return layout
+}
+
+func renderGoldenRow(t *testing.T, row goldenRow) *image.NRGBA {
+	photo, err := os.Open(filepath.Join("testdata", "thumbnails", "photos", row.Photo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer photo.Close()
+	decoded, err := decodePhoto(photo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var img *image.NRGBA
+	switch {
+	case row.Ght != nil:
+		img, err = transformGhtImage(mustLoadLayout(t, GhtThumbnailLayout), row.Ght, decoded, nil)
+	case row.Ant != nil:
+		img, err = transformAntImage(mustLoadLayout(t, AntThumbnailLayout), row.Ant, decoded, nil)
+	default:
+		t.Fatalf("row %s has no data", row.Name)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// compareGolden returns the fraction of pixels that differ, the largest distance, and an image of the golden faded
+// with the differing pixels in red.
+func compareGolden(golden, got image.Image) (differ, largest float64, diff *image.NRGBA) {
+	bounds := golden.Bounds()
+	diff = image.NewNRGBA(bounds)
+	if got.Bounds() != bounds {
+		return 1, math.Inf(1), diff
+	}
+	var count int
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			a := color.NRGBAModel.Convert(golden.At(x, y)).(color.NRGBA)
+			b := color.NRGBAModel.Convert(got.At(x, y)).(color.NRGBA)
+			d := redmeanDistance(a, b)
+			if d > largest {
+				largest = d
+			}
+			if d > goldenPixelThreshold {
+				count++
+				diff.SetNRGBA(x, y, color.NRGBA{R: 255, A: 255})
+				continue
+			}
+			grey := uint8((int(a.R) + int(a.G) + int(a.B)) / 3 / 4)
+			diff.SetNRGBA(x, y, color.NRGBA{R: grey, G: grey, B: grey, A: 255})
+		}
+	}
+	return float64(count) / float64(bounds.Dx()*bounds.Dy()), largest, diff
+}
+
+// redmeanDistance approximates how different two colours look, weighting the channels by how sensitive we are to them.
+func redmeanDistance(a, b color.NRGBA) float64 {
+	rmean := (float64(a.R) + float64(b.R)) / 2
+	dr, dg, db := float64(a.R)-float64(b.R), float64(a.G)-float64(b.G), float64(a.B)-float64(b.B)
+	return math.Sqrt((2+rmean/256)*dr*dr + 4*dg*dg + (2+(255-rmean)/256)*db*db)
+}
+
+func readGoldenPNG(t *testing.T, fname string) image.Image {
+	f, err := os.Open(fname)
+	if err != nil {
+		t.Fatalf("%v (run go test -run TestGoldenThumbnails -update to create it)", err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func writeGoldenPNG(t *testing.T, fname string, img image.Image) {
+	if err := os.MkdirAll(filepath.Dir(fname), 0777); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+var goldenReportTemplate = template.Must(template.New("main").Funcs(template.FuncMap{
+	"percent": func(f float64) string { return fmt.Sprintf("%.3f%%", f*100) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Golden thumbnail failures</title>
+<style>
+body { font-family: sans-serif; margin: 2rem; }
+img { width: 32%; }
+</style>
+</head>
+<body>
+<h1>Golden thumbnail failures</h1>
+{{ range . }}
+<h2>{{ .Name }}: {{ percent .Differ }} of pixels differ</h2>
+<p>Golden, got, and differences in red.</p>
+<img src="file://{{ .Golden }}"> <img src="file://{{ .Got }}"> <img src="file://{{ .Diff }}">
+{{ end }}
+</body>
+</html>
+`))
+
+func writeGoldenReport(t *testing.T, fname string, failures []*goldenFailure) {
+	f, err := os.Create(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := goldenReportTemplate.Execute(f, failures); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestThumbnailImagePrepare(t *testing.T) {
+	// a layout in another folder, with its image next to it
+	dir, err := ioutil.TempDir("", "thumbnail-image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mark := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	writeGoldenPNG(t, filepath.Join(dir, "mark.png"), mark)
+
+	zero, half := 0.0, 0.5
+	for _, test := range []struct {
+		name    string
+		image   thumbnailImage
+		opacity float64
+		size    image.Point
+		err     bool
+	}{
+		{name: "not set", image: thumbnailImage{File: "mark.png"}, opacity: 1, size: image.Pt(40, 20)},
+		{name: "zero", image: thumbnailImage{File: "mark.png", Opacity: &zero}, opacity: 0, size: image.Pt(40, 20)},
+		{name: "half, by width", image: thumbnailImage{File: "mark.png", Width: 20, Opacity: &half}, opacity: 0.5, size: image.Pt(20, 10)},
+		{name: "by height", image: thumbnailImage{File: "mark.png", Height: 40}, opacity: 1, size: image.Pt(80, 40)},
+		{name: "absolute", image: thumbnailImage{File: filepath.Join(dir, "mark.png")}, opacity: 1, size: image.Pt(40, 20)},
+		{name: "missing", image: thumbnailImage{File: "logo.png"}, err: true},
+	} {
+		li := test.image
+		err := li.prepare(dir)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if li.opacity != test.opacity {
+			t.Errorf("%s: opacity %g, expected %g", test.name, li.opacity, test.opacity)
+		}
+		if size := li.image.Bounds().Size(); size != test.size {
+			t.Errorf("%s: size %v, expected %v", test.name, size, test.size)
+		}
+	}
+}
+
+func TestFitTextFractionalMinSize(t *testing.T) {
+	f, err := getFont("JosefinSans-Bold.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fonts := []*truetype.Font{f}
+	text := "Kanchenjunga Base Camp to Ghunsa"
+	width := font.MeasureString(newFallbackFace(fonts, 55.5), text).Round()
+	if font.MeasureString(newFallbackFace(fonts, 56), text).Round() <= width {
+		t.Fatal("the text should only fit at 55.5")
+	}
+
+	lines, size, _, ok := fitText(fonts, text, width, 75, 55.5, 1)
+	if !ok || size != 55.5 || len(lines) != 1 {
+		t.Errorf("got %v at %v (ok %v), expected one line at 55.5", lines, size, ok)
+	}
+	if _, _, _, ok := fitText(fonts, text, width-1, 75, 55.5, 1); ok {
+		t.Error("expected it not to fit below the minimum size")
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
@@ -43,7 +44,7 @@ func getPlaylist(srv *youtube.Service, playlist string) ([]*youtube.PlaylistItem
 	return all, nil
 }
 
-func getAntVideos(srv *youtube.Service, data []*AntVideoData) error {
+func getAntVideos(srv *youtube.Service, state *State, data []*AntVideoData) error {
 
 	var all []*youtube.Video
 
@@ -81,6 +82,12 @@ func getAntVideos(srv *youtube.Service, data []*AntVideoData) error {
 		}
 	}
 
+	missing, err := getMissingVideos(srv, state, all)
+	if err != nil {
+		return fmt.Errorf("getting videos from state: %w", err)
+	}
+	all = append(all, missing...)
+
 	// Check for meta data on each video and ignore those without meta data
 	for _, v := range all {
 
@@ -119,12 +126,13 @@ func getAntVideos(srv *youtube.Service, data []*AntVideoData) error {
 		}
 
 		item.Video = v
+		state.Videos[matches[1]] = v.Id
 	}
 
 	return nil
 }
 
-func getGhtVideos(srv *youtube.Service, data []*GhtVideoData) error {
+func getGhtVideos(srv *youtube.Service, state *State, data []*GhtVideoData) error {
 
 	var all []*youtube.Video
 
@@ -162,6 +170,12 @@ func getGhtVideos(srv *youtube.Service, data []*GhtVideoData) error {
 		}
 	}
 
+	missing, err := getMissingVideos(srv, state, all)
+	if err != nil {
+		return fmt.Errorf("getting videos from state: %w", err)
+	}
+	all = append(all, missing...)
+
 	// Check for meta data on each video and ignore those without meta data
 	for _, v := range all {
 
@@ -202,11 +216,112 @@ func getGhtVideos(srv *youtube.Service, data []*GhtVideoData) error {
 		}
 
 		item.Video = v
+		state.Videos[matches[1]] = v.Id
 	}
 
 	return nil
 }
 
+// getMissingVideos fetches the videos recorded in the state that weren't returned by the search. Newly inserted
+// videos can take a while to show up in search results.
+func getMissingVideos(srv *youtube.Service, state *State, found []*youtube.Video) ([]*youtube.Video, error) {
+	seen := map[string]bool{}
+	for _, v := range found {
+		seen[v.Id] = true
+	}
+	var ids []string
+	for _, id := range state.Videos {
+		if !seen[id] {
+			ids = append(ids, id)
+		}
+	}
+	var all []*youtube.Video
+	for len(ids) > 0 {
+		batch := ids
+		if len(batch) > 50 {
+			batch = batch[:50]
+		}
+		ids = ids[len(batch):]
+		videosResponse, err := srv.Videos.List(ApiPartsRead).Id(strings.Join(batch, ",")).Do()
+		if err != nil {
+			return nil, fmt.Errorf("youtube videos list call: %w", err)
+		}
+		all = append(all, videosResponse.Items...)
+	}
+	return all, nil
+}
+
+// managedEpisode is a video we manage (i.e. one with meta data in the description), for either expedition.
+type managedEpisode struct {
+	Expedition string
+	Key        int
+	Type       string
+	Section    string
+	LiveTime   time.Time
+	Title      string
+	VideoId    string
+	Video      *youtube.Video
+}
+
+// getManagedEpisodes finds the videos for both expeditions.
+func getManagedEpisodes(srv *youtube.Service) ([]*managedEpisode, error) {
+
+	state, err := loadState()
+	if err != nil {
+		return nil, fmt.Errorf("loading state: %w", err)
+	}
+
+	var episodes []*managedEpisode
+
+	ghtData, err := getGhtData()
+	if err != nil {
+		return nil, fmt.Errorf("can't load ght days: %w", err)
+	}
+	if err := getGhtVideos(srv, state, ghtData); err != nil {
+		return nil, fmt.Errorf("getting ght videos: %w", err)
+	}
+	for _, item := range ghtData {
+		if item.Video == nil {
+			continue
+		}
+		episodes = append(episodes, &managedEpisode{
+			Expedition: item.Expedition,
+			Key:        item.Key,
+			Type:       item.Type,
+			Section:    item.Section,
+			LiveTime:   item.LiveTime,
+			Title:      item.Video.Snippet.Title,
+			VideoId:    item.Video.Id,
+			Video:      item.Video,
+		})
+	}
+
+	antData, err := getAntData()
+	if err != nil {
+		return nil, fmt.Errorf("can't load ant days: %w", err)
+	}
+	if err := getAntVideos(srv, state, antData); err != nil {
+		return nil, fmt.Errorf("getting ant videos: %w", err)
+	}
+	for _, item := range antData {
+		if item.Video == nil {
+			continue
+		}
+		episodes = append(episodes, &managedEpisode{
+			Expedition: item.Expedition,
+			Key:        item.Key,
+			Type:       item.Type,
+			Section:    "Antarctica",
+			LiveTime:   item.LiveTime,
+			Title:      item.Video.Snippet.Title,
+			VideoId:    item.Video.Id,
+			Video:      item.Video,
+		})
+	}
+
+	return episodes, nil
+}
+
 func getYoutubeService(ctx context.Context) (*youtube.Service, error) {
 
 	var filename string
@@ -223,12 +338,12 @@ func getYoutubeService(ctx context.Context) (*youtube.Service, error) {
 
 	// If modifying these scopes, delete your previously saved credentials
 	// at ~/.credentials/youtube-go-quickstart.json
-	config, err := google.ConfigFromJSON(b, youtube.YoutubeScope)
+	config, err := google.ConfigFromJSON(b, youtube.YoutubeForceSslScope)
 	if err != nil {
 		return nil, fmt.Errorf("parsing client secret file to config: %w", err)
 	}
 
-	client, err := getYoutubeClient(ctx, config)
+	client, err := getYoutubeClient(ctx, config, "youtube_token.json")
 	if err != nil {
 		return nil, fmt.Errorf("getting youtube client: %w", err)
 	}
@@ -243,8 +358,7 @@ func getYoutubeService(ctx context.Context) (*youtube.Service, error) {
 
 // getClient uses a Context and Config to retrieve a Token
 // then generate a Client. It returns the generated Client.
-func getYoutubeClient(ctx context.Context, config *oauth2.Config) (*http.Client, error) {
-	fname := "youtube_token.json"
+func getYoutubeClient(ctx context.Context, config *oauth2.Config, fname string) (*http.Client, error) {
 	tok, err := youtubeTokenFromFile(fname)
 	if err != nil {
 		tok, err = getYoutubeTokenFromWeb(config)